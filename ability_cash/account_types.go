@@ -0,0 +1,49 @@
+package ability_cash
+
+import "strings"
+
+const (
+	AssetAccount     = "A"
+	LiabilityAccount = "L"
+	EquityAccount    = "E"
+	RevenueAccount   = "R"
+	ExpenseAccount   = "X"
+)
+
+// accountRoots maps an AbilityCash account plan or classifier root to the account type
+var accountRoots = map[string]string{
+	"assets":      AssetAccount,
+	"активы":      AssetAccount,
+	"liabilities": LiabilityAccount,
+	"debts":       LiabilityAccount,
+	"пассивы":     LiabilityAccount,
+	"долги":       LiabilityAccount,
+	"equity":      EquityAccount,
+	"капитал":     EquityAccount,
+	"income":      RevenueAccount,
+	"incomes":     RevenueAccount,
+	"доходы":      RevenueAccount,
+	"expenses":    ExpenseAccount,
+	"расходы":     ExpenseAccount,
+}
+
+func (c *LedgerConverter) AccountTypes() map[string]string {
+	types := make(map[string]string, len(c.accounts))
+
+	money := make(map[string]bool)
+	for _, account := range *c.Db.GetAccounts() {
+		money[path(account.Name)] = true
+	}
+
+	for account, source := range c.accounts {
+		root := strings.SplitN(source, ":", 2)[0]
+
+		if t, ok := accountRoots[strings.ToLower(root)]; ok {
+			types[account] = t
+		} else if money[source] {
+			types[account] = AssetAccount
+		}
+	}
+
+	return types
+}
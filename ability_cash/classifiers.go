@@ -0,0 +1,138 @@
+package ability_cash
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+// Category roles of the scope configuration, a category without a role becomes a tag
+const (
+	PayeeRole   = "payee"
+	AccountRole = "account"
+	TagRole     = "tag"
+)
+
+const samplesCount = 3
+
+var payeeRoots = map[string]bool{
+	"payee":       true,
+	"payees":      true,
+	"agent":       true,
+	"agents":      true,
+	"provider":    true,
+	"providers":   true,
+	"получатель":  true,
+	"получатели":  true,
+	"контрагент":  true,
+	"контрагенты": true,
+	"плательщик":  true,
+	"магазин":     true,
+	"магазины":    true,
+}
+
+// Classifier collects the usage of a category root
+type Classifier struct {
+	Name    string
+	Uses    int
+	Values  int
+	Depth   int
+	Samples []string
+	values  map[string]bool
+}
+
+// ClassifiersAnalysis collects declared category roots and the ones found in transactions
+type ClassifiersAnalysis struct {
+	index map[string]*Classifier
+}
+
+func NewClassifiersAnalysis(db schema.Database) *ClassifiersAnalysis {
+	a := &ClassifiersAnalysis{index: make(map[string]*Classifier)}
+
+	if source, ok := db.(schema.ClassifiersSource); ok {
+		for _, name := range source.GetClassifiers() {
+			a.classifier(name)
+		}
+	}
+
+	return a
+}
+
+func (a *ClassifiersAnalysis) Add(tx ledger.Transaction) {
+	tags := append([]string{}, tx.Tags...)
+	for _, item := range tx.Items {
+		tags = append(tags, item.Tags...)
+	}
+
+	for _, tag := range tags {
+		parts := strings.Split(strings.TrimPrefix(tag, "\\"), "\\")
+		a.classifier(parts[0]).add(parts[1:])
+	}
+}
+
+// Classifiers returns the most used first
+func (a *ClassifiersAnalysis) Classifiers() []*Classifier {
+	classifiers := make([]*Classifier, 0, len(a.index))
+	for _, classifier := range a.index {
+		classifiers = append(classifiers, classifier)
+	}
+
+	sort.Slice(classifiers, func(i, k int) bool {
+		if classifiers[i].Uses != classifiers[k].Uses {
+			return classifiers[i].Uses > classifiers[k].Uses
+		}
+
+		return classifiers[i].Name < classifiers[k].Name
+	})
+
+	return classifiers
+}
+
+func (a *ClassifiersAnalysis) classifier(name string) *Classifier {
+	if _, ok := a.index[name]; !ok {
+		a.index[name] = &Classifier{Name: name, values: make(map[string]bool)}
+	}
+
+	return a.index[name]
+}
+
+func (c *Classifier) add(parts []string) {
+	c.Uses++
+
+	if len(parts) > c.Depth {
+		c.Depth = len(parts)
+	}
+
+	value := strings.Join(parts, "\\")
+
+	if value == "" || c.values[value] {
+		return
+	}
+
+	c.values[value] = true
+	c.Values++
+
+	if len(c.Samples) < samplesCount {
+		c.Samples = append(c.Samples, value)
+	}
+}
+
+// GuessRole relies on well known names first, then on the shape: deep trees are accounts, long flat lists are payees
+func (c *Classifier) GuessRole() string {
+	name := strings.ToLower(c.Name)
+
+	switch {
+	case payeeRoots[name]:
+		return PayeeRole
+	case accountRoots[name] != "":
+		return AccountRole
+	case c.Depth > 1:
+		return AccountRole
+	case c.Values > 10:
+		return PayeeRole
+	default:
+		return TagRole
+	}
+}
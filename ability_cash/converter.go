@@ -1,7 +1,6 @@
 package ability_cash
 
 import (
-	"math"
 	"sort"
 	"strings"
 	"time"
@@ -10,11 +9,28 @@ import (
 	"github.com/Bishop/abilitycash2ledger/ledger"
 )
 
+const (
+	// OpeningBalanceId identifies the generated opening balance transaction
+	OpeningBalanceId = "opening-balance"
+
+	IdTag      = "ac-id"
+	ChangedTag = "ac-changed"
+)
+
 type LedgerConverter struct {
-	GenerateEquity bool
-	Db             schema.Database
-	Categories     map[string]string
-	accounts       map[string]string
+	GenerateEquity  bool
+	Assertions      bool
+	Db              schema.Database
+	Categories      map[string]string
+	FallbackAccount string
+	Rules           []*Rule // compiled with CompileRules
+	Costs           []*CostRule
+	BaseCurrency    string
+	Observe         func(ledger.Transaction) // sees every source transaction before it is converted
+	accounts        map[string]string
+	prices          []schema.Rate
+	currencies      map[string]bool
+	err             error
 }
 
 type Tags struct {
@@ -26,94 +42,341 @@ type Tags struct {
 
 func (c *LedgerConverter) Transactions() <-chan ledger.Transaction {
 	c.accounts = make(map[string]string)
+	c.prices = nil
+	c.currencies = make(map[string]bool)
 
 	txs := make(chan ledger.Transaction)
 
 	go func() {
-		c.transactions(txs)
+		c.err = c.transactions(txs)
 		close(txs)
 	}()
 
 	return txs
 }
 
-func (c *LedgerConverter) transactions(txs chan<- ledger.Transaction) {
-	if c.GenerateEquity {
-		tx := ledger.Transaction{
-			Date:    time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local),
-			Payee:   "Opening Balance",
-			Cleared: true,
-			Items:   make([]ledger.TxItem, 0),
+// Budget converts scheduled transactions like the regular ones, call it after Transactions to declare their accounts too
+func (c *LedgerConverter) Budget() []ledger.Transaction {
+	source, ok := c.Db.(schema.ScheduleSource)
+	if !ok {
+		return nil
+	}
+
+	if c.accounts == nil {
+		c.accounts = make(map[string]string)
+		c.currencies = make(map[string]bool)
+	}
+
+	budget := make([]ledger.Transaction, 0)
+
+	for _, tx := range source.GetScheduled() {
+		// a schedule has no running balance to assert
+		items := make([]ledger.TxItem, len(tx.Items))
+		for i, item := range tx.Items {
+			item.HasRunningBalance = false
+			items[i] = item
 		}
+		tx.Items = items
 
-		for _, account := range *c.Db.GetAccounts() {
-			if account.InitBalance == 0 {
-				continue
-			}
+		tx = c.transaction(tx)
+		c.useCurrencies(tx)
+		budget = append(budget, tx)
+	}
 
-			tx.Items = append(tx.Items, ledger.TxItem{
-				Account:  c.account(account.Name),
-				Currency: account.Currency,
-				Amount:   account.InitBalance,
-			})
+	return budget
+}
+
+// Err returns the error which stopped the source transactions stream, it is valid after the channel is closed
+func (c *LedgerConverter) Err() error {
+	return c.err
+}
+
+// OpeningBalance is the transaction of the initial account balances, GenerateEquity writes it first
+func (c *LedgerConverter) OpeningBalance() ledger.Transaction {
+	if c.accounts == nil {
+		c.accounts = make(map[string]string)
+		c.currencies = make(map[string]bool)
+	}
+
+	tx := ledger.Transaction{
+		Id:      OpeningBalanceId,
+		Date:    time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local),
+		Payee:   "Opening Balance",
+		Cleared: true,
+		Items:   make([]ledger.TxItem, 0),
+	}
+
+	for _, account := range *c.Db.GetAccounts() {
+		if account.InitBalance.IsZero() {
+			continue
+		}
+
+		tx.Items = append(tx.Items, ledger.TxItem{
+			Account:  path(account.Name),
+			Currency: account.Currency,
+			Amount:   account.InitBalance,
+		})
+	}
+
+	tx.Items = append(tx.Items, ledger.TxItem{Account: ledger.OpeningBalance})
+	c.applyRules(&tx, make([]bool, len(tx.Items)), nil)
+
+	return tx
+}
+
+func (c *LedgerConverter) transactions(txs chan<- ledger.Transaction) error {
+	if c.GenerateEquity {
+		tx := c.OpeningBalance()
+		c.useCurrencies(tx)
+		txs <- tx
+	}
+
+	return schema.EachTransaction(c.Db, func(tx ledger.Transaction) error {
+		if c.Observe != nil {
+			c.Observe(tx)
 		}
 
-		tx.Items = append(tx.Items, ledger.TxItem{Account: ledger.OpeningBalance})
+		tx = c.transaction(tx)
+		c.addPrice(tx)
+		c.useCurrencies(tx)
 		txs <- tx
+
+		return nil
+	})
+}
+
+func (c *LedgerConverter) transaction(tx ledger.Transaction) ledger.Transaction {
+	categories := make([]string, 0)
+	for _, tag := range tx.Tags {
+		categories = append(categories, path(tag))
+	}
+	for _, item := range tx.Items {
+		for _, tag := range item.Tags {
+			categories = append(categories, path(tag))
+		}
 	}
 
-	for _, tx := range *c.Db.GetTransactions() {
-		tags := c.createTags(tx.Tags)
-		tx.Tags = nil
-		tx.Metadata = tags.Tags
+	tags := c.createTags(tx.Tags)
+	tx.Tags = nil
+	tx.Metadata = tags.Tags
+	sourceMetadata(tx.Metadata, tx.Id, tx.ChangedAt)
+
+	if tx.Payee == "" {
+		tx.Payee = tags.Payee
+	}
+
+	postings := make([]ledger.TxItem, 0, len(tx.Items)*2+1)
+	counter := make([]bool, 0, cap(postings))
+	split := false
+
+	// posting level categories (split transactions) are balanced right after their posting
+	for _, item := range tx.Items {
+		itemTags := c.createTags(item.Tags)
+		item.Tags = nil
+
+		// readers balance postings they can not attribute with ledger.Unknown, it stands for the fallback account
+		fallback := item.Account == ledger.Unknown
+		if fallback {
+			item.Account = c.fallbackAccount()
+		}
+
+		item.Account = path(item.Account)
+
+		sourceMetadata(itemTags.Tags, item.Id, time.Time{})
+
+		if len(itemTags.Tags) > 0 {
+			item.Metadata = itemTags.Tags
+		}
 
 		if tx.Payee == "" {
-			tx.Payee = tags.Payee
-		}
-
-		if tx.Payee == "" && len(tx.Items) == 2 {
-			if tx.Items[0].Currency == tx.Items[1].Currency {
-				tx.Payee = "Transfer"
-
-				if math.Abs(tx.Items[0].Amount) == math.Abs(tx.Items[1].Amount) {
-					index := 0
-					if tx.Items[1].Amount < 0 {
-						index = 1
-					}
-					tx.Items[index].Amount = 0
-					tx.Items[index].Currency = ""
-				}
-			} else {
-				tx.Payee = "Exchange"
-			}
+			tx.Payee = itemTags.Payee
+		}
+
+		if c.Assertions && item.HasRunningBalance && !item.HasBalanceAssertion {
+			item.BalanceAssertion, item.HasBalanceAssertion = item.RunningBalance, true
 		}
 
-		if tags.Account != "" {
-			tx.Items = append(tx.Items, ledger.TxItem{Account: tags.Account})
+		postings = append(postings, item)
+		counter = append(counter, fallback)
+		split = split || fallback
+
+		if itemTags.Account != "" {
+			postings = append(postings, ledger.TxItem{
+				Account:  path(itemTags.Account),
+				Currency: item.Currency,
+				Amount:   item.Amount.Neg(),
+				Payee:    itemTags.ItemPayee,
+			})
+			counter = append(counter, true)
+			split = true
+		}
+	}
+
+	// a split balanced by its categories is not a transfer
+	if tx.Payee == "" && tags.Account == "" && !split {
+		tx.Payee = transferPayee(postings)
+
+		if tx.Payee == "Transfer" {
+			elideNegative(postings)
+		}
+	}
+
+	account := c.fallbackAccount()
+	if tags.Account != "" {
+		account = path(tags.Account)
+	}
 
-			if tx.Items[0].Amount < 0 {
-				tx.Items[1].Amount, tx.Items[0].Amount = -tx.Items[0].Amount, 0
-				tx.Items[1].Currency, tx.Items[0].Currency = tx.Items[0].Currency, ""
+	balanced := c.balance(postings, account)
+	appended := len(balanced) > len(postings)
+
+	if appended {
+		postings, counter = balanced, append(counter, true)
+	}
+
+	if tx.Payee == "" {
+		tx.Payee = tags.ItemPayee
+	} else if tags.Account != "" && appended {
+		postings[len(postings)-1].Payee = tags.ItemPayee
+	}
+
+	tx.Items = postings
+
+	c.applyRules(&tx, counter, categories)
+	c.applyCosts(&tx)
+
+	return tx
+}
+
+// sourceMetadata keeps AbilityCash identity to trace the journal back to the source
+func sourceMetadata(metadata map[string]string, id string, changedAt time.Time) {
+	if id != "" {
+		metadata[IdTag] = id
+	}
+
+	if !changedAt.IsZero() {
+		metadata[ChangedTag] = changedAt.Format("2006-01-02T15:04:05")
+	}
+}
+
+// balance sends the remainder to the account, a remainder in several currencies is left for ledger to convert
+func (c *LedgerConverter) balance(postings []ledger.TxItem, account string) []ledger.TxItem {
+	for _, posting := range postings {
+		if posting.Amount.IsZero() {
+			return postings
+		}
+	}
+
+	// a remainder which does not fit an amount is left for the verification to report
+	rest, err := remainder(postings)
+
+	if err != nil || len(rest) != 1 {
+		return postings
+	}
+
+	balancing := ledger.TxItem{Account: account}
+
+	for currency, amount := range rest {
+		if amount.Sign() < 0 {
+			balancing.Amount, balancing.Currency = amount.Neg(), currency
+
+			// an asserted posting keeps its amount, without it the assertion would become an assignment
+			if len(postings) == 1 && !postings[0].HasBalanceAssertion {
+				postings[0].Amount = ledger.Amount{}
 			}
 		}
+	}
 
-		if tx.Payee == "" {
-			tx.Payee = tags.ItemPayee
-		} else {
-			tx.Items[1].Payee = tags.ItemPayee
+	return append(postings, balancing)
+}
+
+func (c *LedgerConverter) fallbackAccount() string {
+	if c.FallbackAccount == "" {
+		return ledger.Unknown
+	}
+
+	return c.FallbackAccount
+}
+
+// transferPayee names a transaction between own accounts
+func transferPayee(postings []ledger.TxItem) string {
+	currencies := make(map[string]bool)
+	count := 0
+
+	for _, posting := range postings {
+		if !posting.Amount.IsZero() {
+			currencies[posting.Currency] = true
+			count++
 		}
+	}
+
+	switch {
+	case count < 2:
+		return ""
+	case len(currencies) == 1:
+		return "Transfer"
+	default:
+		return "Exchange"
+	}
+}
+
+// elideNegative leaves one amount for ledger to calculate, when the transfer is balanced. A posting with a balance
+// assertion keeps its amount, the other one is elided then.
+func elideNegative(postings []ledger.TxItem) {
+	if rest, err := remainder(postings); err != nil || len(rest) > 0 {
+		return
+	}
 
-		tx.Items[0].Account = c.account(tx.Items[0].Account)
-		tx.Items[1].Account = c.account(tx.Items[1].Account)
+	elided := -1
 
-		txs <- tx
+	for i, posting := range postings {
+		if posting.HasBalanceAssertion {
+			continue
+		}
+
+		if posting.Amount.Sign() < 0 {
+			elided = i
+			break
+		}
+
+		if elided < 0 {
+			elided = i
+		}
+	}
+
+	if elided >= 0 {
+		postings[elided].Amount = ledger.Amount{}
 	}
 }
 
+func remainder(postings []ledger.TxItem) (map[string]ledger.Amount, error) {
+	sums := make(map[string]ledger.Amount)
+
+	for _, posting := range postings {
+		if posting.Amount.IsZero() {
+			continue
+		}
+
+		sum, err := sums[posting.Currency].Add(posting.Amount)
+		if err != nil {
+			return nil, err
+		}
+
+		sums[posting.Currency] = sum
+	}
+
+	for currency, sum := range sums {
+		if sum.IsZero() {
+			delete(sums, currency)
+		}
+	}
+
+	return sums, nil
+}
+
 func (c *LedgerConverter) Accounts() []string {
 	list := make([]string, 0, len(c.accounts))
 
-	for _, account := range c.accounts {
+	for account := range c.accounts {
 		list = append(list, account)
 	}
 
@@ -122,16 +385,13 @@ func (c *LedgerConverter) Accounts() []string {
 	return list
 }
 
-func (c *LedgerConverter) account(s string) string {
-	a, ok := c.accounts[s]
-
-	if !ok {
-		a = strings.Replace(s, "Assets\\", "", 1)
-		a = strings.Replace(a, "\\", ":", -1)
-		c.accounts[s] = a
+// register remembers the output account with the source path it came from
+func (c *LedgerConverter) register(account string, source string) string {
+	if _, ok := c.accounts[account]; !ok {
+		c.accounts[account] = source
 	}
 
-	return a
+	return account
 }
 
 func (c *LedgerConverter) createTags(tags []string) *Tags {
@@ -141,9 +401,9 @@ func (c *LedgerConverter) createTags(tags []string) *Tags {
 	for _, tag := range tags {
 		parts := strings.SplitN(tag, "\\", 2)
 		switch c.Categories[parts[0]] {
-		case "payee":
+		case PayeeRole:
 			t.Payee = c.lastPart(tag)
-		case "account":
+		case AccountRole:
 			t.Account = tag
 
 			if strings.Count(t.Account, "\\") == 3 {
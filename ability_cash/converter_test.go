@@ -0,0 +1,172 @@
+package ability_cash
+
+import (
+	"testing"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+func testConverter() *LedgerConverter {
+	return &LedgerConverter{
+		Categories: map[string]string{"Expenses": AccountRole, "Income": AccountRole, "Payee": PayeeRole},
+		accounts:   make(map[string]string),
+	}
+}
+
+func item(account, amount, currency string, tags ...string) ledger.TxItem {
+	value, err := ledger.ParseAmount(amount)
+	if err != nil {
+		panic(err)
+	}
+
+	return ledger.TxItem{Account: account, Currency: currency, Amount: value, Tags: tags}
+}
+
+// balanced adds the running balance of the account after the posting
+func balanced(posting ledger.TxItem, balance string) ledger.TxItem {
+	posting.RunningBalance, posting.HasRunningBalance = item("", balance, "").Amount, true
+
+	return posting
+}
+
+// postings renders "account amount currency = assertion currency" lines, an elided amount is left out
+func postings(tx ledger.Transaction) []string {
+	lines := make([]string, len(tx.Items))
+
+	for i, item := range tx.Items {
+		lines[i] = item.Account
+		if !item.Amount.IsZero() {
+			lines[i] += " " + item.Amount.String() + " " + item.Currency
+		}
+		if item.HasBalanceAssertion {
+			lines[i] += " = " + item.BalanceAssertion.String() + " " + item.Currency
+		}
+		if item.Payee != "" {
+			lines[i] += " ; Payee: " + item.Payee
+		}
+	}
+
+	return lines
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestTransaction(t *testing.T) {
+	tests := []struct {
+		name  string
+		tx    ledger.Transaction
+		payee string
+		want  []string
+	}{
+		{
+			name:  "transfer",
+			tx:    ledger.Transaction{Items: []ledger.TxItem{item("Card", "-10", "RUB"), item("Cash", "10", "RUB")}},
+			payee: "Transfer",
+			want:  []string{"Card", "Cash 10 RUB"},
+		},
+		{
+			name: "split balanced by categories",
+			tx: ledger.Transaction{Items: []ledger.TxItem{
+				item("Cash", "-30", "RUB", "Expenses\\Food"),
+				item("Cash", "-20", "RUB", "Expenses\\Home"),
+			}},
+			want: []string{"Cash -30 RUB", "Expenses:Food 30 RUB", "Cash -20 RUB", "Expenses:Home 20 RUB"},
+		},
+		{
+			name: "split balanced by the reader",
+			tx: ledger.Transaction{Items: []ledger.TxItem{
+				item("Cash", "-30", "RUB"),
+				item(ledger.Unknown, "30", "RUB"),
+				item("Cash", "-20", "RUB"),
+				item(ledger.Unknown, "20", "RUB"),
+			}},
+			want: []string{"Cash -30 RUB", "Equity:Unknown 30 RUB", "Cash -20 RUB", "Equity:Unknown 20 RUB"},
+		},
+		{
+			name: "item payee without a balancing posting",
+			tx: ledger.Transaction{
+				Tags:  []string{"Payee\\Shop", "Expenses\\Travel\\Tickets\\Airline"},
+				Items: []ledger.TxItem{item("Card", "-3000", "RUB"), item("Wallet", "40", "USD")},
+			},
+			payee: "Shop",
+			want:  []string{"Card -3000 RUB", "Wallet 40 USD"},
+		},
+		{
+			name: "item payee on the balancing posting",
+			tx: ledger.Transaction{
+				Tags:  []string{"Payee\\Shop", "Expenses\\Travel\\Tickets\\Airline"},
+				Items: []ledger.TxItem{item("Card", "-3000", "RUB")},
+			},
+			payee: "Shop",
+			want:  []string{"Card", "Expenses:Travel:Tickets 3000 RUB ; Payee: Airline"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := testConverter().transaction(tt.tx)
+
+			if tx.Payee != tt.payee || !equalLines(postings(tx), tt.want) {
+				t.Errorf("got %q %q, want %q %q", tx.Payee, postings(tx), tt.payee, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransactionAssertions(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   ledger.Transaction
+		want []string
+	}{
+		{
+			name: "zero balance",
+			tx: ledger.Transaction{
+				Tags:  []string{"Expenses\\Food"},
+				Items: []ledger.TxItem{balanced(item("Cash", "-30", "RUB"), "0")},
+			},
+			want: []string{"Cash -30 RUB = 0 RUB", "Expenses:Food 30 RUB"},
+		},
+		{
+			name: "single posting keeps its amount",
+			tx:   ledger.Transaction{Items: []ledger.TxItem{balanced(item("Cash", "-30", "RUB"), "70")}},
+			want: []string{"Cash -30 RUB = 70 RUB", "Equity:Unknown 30 RUB"},
+		},
+		{
+			name: "transfer elides the posting without an assertion",
+			tx:   ledger.Transaction{Items: []ledger.TxItem{balanced(item("Card", "-10", "RUB"), "90"), item("Cash", "10", "RUB")}},
+			want: []string{"Card -10 RUB = 90 RUB", "Cash"},
+		},
+		{
+			name: "transfer with both assertions",
+			tx: ledger.Transaction{Items: []ledger.TxItem{
+				balanced(item("Card", "-10", "RUB"), "90"),
+				balanced(item("Cash", "10", "RUB"), "10"),
+			}},
+			want: []string{"Card -10 RUB = 90 RUB", "Cash 10 RUB = 10 RUB"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := testConverter()
+			converter.Assertions = true
+
+			if got := postings(converter.transaction(tt.tx)); !equalLines(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
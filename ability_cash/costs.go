@@ -0,0 +1,98 @@
+package ability_cash
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+const costPrecision = 8
+
+// CostRule annotates exchanges with a posting in the Account subtree: an empty Account matches every exchange
+type CostRule struct {
+	Account string `json:"account"`
+	Mode    string `json:"mode"`
+}
+
+func CheckCostRules(rules []*CostRule) error {
+	for i, rule := range rules {
+		switch rule.Mode {
+		case ledger.UnitCost, ledger.TotalCost, ledger.LotCost:
+		default:
+			return fmt.Errorf("cost rule %d: unknown mode %q, use %q, %q or %q", i+1, rule.Mode, ledger.UnitCost, ledger.TotalCost, ledger.LotCost)
+		}
+	}
+
+	return nil
+}
+
+func (r *CostRule) contains(account string) bool {
+	return r.Account == "" || account == r.Account || strings.HasPrefix(account, r.Account+":")
+}
+
+// applyCosts prices one posting of a two currency exchange in the currency of the other one. The first rule
+// with a posting in its subtree wins; that posting gets the cost, the received one if both are there.
+// A lot can not be told for a sale, so it gets the unit price.
+func (c *LedgerConverter) applyCosts(tx *ledger.Transaction) {
+	a, b, ok := exchangePostings(tx)
+	if !ok {
+		return
+	}
+
+	for _, rule := range c.Costs {
+		item, other := a, b
+
+		switch {
+		case rule.contains(a.Account):
+		case rule.contains(b.Account):
+			item, other = b, a
+		default:
+			continue
+		}
+
+		item.Cost, item.CostCurrency, item.CostMode = other.Amount.Abs(), other.Currency, rule.Mode
+
+		if rule.Mode == ledger.LotCost && item.Amount.Sign() < 0 {
+			item.CostMode = ledger.UnitCost
+		}
+
+		if item.CostMode != ledger.TotalCost {
+			// a unit price which does not fit the precision is given as the total one
+			if unit, err := item.Cost.Quo(item.Amount.Abs(), costPrecision); err == nil {
+				item.Cost = unit.Normalize()
+			} else {
+				item.CostMode = ledger.TotalCost
+			}
+		}
+
+		return
+	}
+}
+
+// exchangePostings finds the received and the paid postings of an exchange between two currencies
+func exchangePostings(tx *ledger.Transaction) (*ledger.TxItem, *ledger.TxItem, bool) {
+	exchange := make([]int, 0, 2)
+
+	for i, item := range tx.Items {
+		if !item.Amount.IsZero() {
+			exchange = append(exchange, i)
+		}
+	}
+
+	if len(exchange) != 2 {
+		return nil, nil, false
+	}
+
+	received, paid := &tx.Items[exchange[0]], &tx.Items[exchange[1]]
+
+	if received.Currency == paid.Currency || received.Amount.Sign() == paid.Amount.Sign() {
+		return nil, nil, false
+	}
+
+	if received.Amount.Sign() < 0 {
+		received, paid = paid, received
+	}
+
+	return received, paid, true
+}
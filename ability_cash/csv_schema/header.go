@@ -0,0 +1,159 @@
+package csv_schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+const (
+	colExecuted       = "executed"
+	colLocked         = "locked"
+	colDate           = "date"
+	colIncomeAccount  = "income account"
+	colIncomeAmount   = "income amount"
+	colIncomeBalance  = "income balance"
+	colExpenseAccount = "expense account"
+	colExpenseAmount  = "expense amount"
+	colExpenseBalance = "expense balance"
+	colComment        = "comment"
+	colRecurrence     = "recurrence"
+	colDayOfMonth     = "day of month"
+	colInterval       = "interval"
+)
+
+var txRequired = []string{
+	colExecuted, colLocked, colDate, colIncomeAccount, colIncomeAmount,
+	colExpenseAccount, colExpenseAmount, colComment,
+}
+
+// txHeaders maps English and Russian AbilityCash header names to columns
+var txHeaders = map[string]string{
+	"executed":        colExecuted,
+	"исполнена":       colExecuted,
+	"исполнено":       colExecuted,
+	"locked":          colLocked,
+	"заблокирована":   colLocked,
+	"заблокировано":   colLocked,
+	"date":            colDate,
+	"дата":            colDate,
+	"income account":  colIncomeAccount,
+	"счет прихода":    colIncomeAccount,
+	"счёт прихода":    colIncomeAccount,
+	"income amount":   colIncomeAmount,
+	"сумма прихода":   colIncomeAmount,
+	"income balance":  colIncomeBalance,
+	"остаток прихода": colIncomeBalance,
+	"баланс прихода":  colIncomeBalance,
+	"expense account": colExpenseAccount,
+	"счет расхода":    colExpenseAccount,
+	"счёт расхода":    colExpenseAccount,
+	"expense amount":  colExpenseAmount,
+	"сумма расхода":   colExpenseAmount,
+	"expense balance": colExpenseBalance,
+	"остаток расхода": colExpenseBalance,
+	"баланс расхода":  colExpenseBalance,
+	"comment":         colComment,
+	"комментарий":     colComment,
+	"recurrence":      colRecurrence,
+	"повторение":      colRecurrence,
+	"повтор":          colRecurrence,
+	"day of month":    colDayOfMonth,
+	"день месяца":     colDayOfMonth,
+	"interval":        colInterval,
+	"интервал":        colInterval,
+}
+
+// recurrences maps English and Russian Recurrence values to period units, an empty unit is a single transaction
+var recurrences = map[string]string{
+	"":            "",
+	"none":        "",
+	"once":        "",
+	"нет":         "",
+	"однократно":  "",
+	"daily":       ledger.Daily,
+	"ежедневно":   ledger.Daily,
+	"weekly":      ledger.Weekly,
+	"еженедельно": ledger.Weekly,
+	"monthly":     ledger.Monthly,
+	"ежемесячно":  ledger.Monthly,
+	"yearly":      ledger.Yearly,
+	"annually":    ledger.Yearly,
+	"ежегодно":    ledger.Yearly,
+}
+
+var categoryPrefixes = []string{"category of ", "категория: ", "категория "}
+
+type layout struct {
+	columns     map[string]int
+	categories  []int
+	classifiers []string
+}
+
+type Record struct {
+	values []string
+	layout *layout
+}
+
+func parseTxHeader(header []string) (*layout, error) {
+	l := &layout{columns: make(map[string]int)}
+
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(name))
+
+		if column, ok := txHeaders[key]; ok {
+			l.columns[column] = i
+			continue
+		}
+
+		if classifier := categoryClassifier(name); classifier != "" {
+			l.categories = append(l.categories, i)
+			l.classifiers = append(l.classifiers, classifier)
+			continue
+		}
+
+		return nil, fmt.Errorf("unknown column %q in sheet %s", name, TransactionsSheet)
+	}
+
+	for _, column := range txRequired {
+		if _, ok := l.columns[column]; !ok {
+			return nil, fmt.Errorf("column %q is missing in sheet %s", column, TransactionsSheet)
+		}
+	}
+
+	return l, nil
+}
+
+func categoryClassifier(name string) string {
+	name = strings.TrimSpace(name)
+
+	for _, prefix := range categoryPrefixes {
+		if len(name) > len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+			return name[len(prefix):]
+		}
+	}
+
+	return ""
+}
+
+func (r Record) Get(column string) string {
+	if i, ok := r.layout.columns[column]; ok && i < len(r.values) {
+		return r.values[i]
+	}
+
+	return ""
+}
+
+// Categories returns non-empty category paths in the column order
+func (r Record) Categories() []string {
+	categories := make([]string, 0)
+
+	for _, i := range r.layout.categories {
+		if i < len(r.values) && r.values[i] != "" {
+			categories = append(categories, r.values[i])
+		}
+	}
+
+	return categories
+}
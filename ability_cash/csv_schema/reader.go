@@ -2,6 +2,7 @@ package csv_schema
 
 import (
 	"encoding/csv"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -9,33 +10,100 @@ import (
 	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
 )
 
+// Sheets of the AbilityCash Excel export
+const (
+	RatesSheet        = "Rates"
+	AccountPlansSheet = "Account plans"
+	AccountsSheet     = "Accounts"
+	TransactionsSheet = "Transactions"
+)
+
+var csvFiles = map[string]string{
+	RatesSheet:        "rates.csv",
+	AccountPlansSheet: "structure.csv",
+	AccountsSheet:     "accounts.csv",
+	TransactionsSheet: "txs.csv",
+}
+
+// SheetReader passes every row of the named sheet, header included, to the handler
+type SheetReader func(sheet string, handler func([]string) error) error
+
 func ReadDatabase(fileName string) (schema.Database, error) {
+	read := func(sheet string, handler func([]string) error) error {
+		return readCsv(fileName, csvFiles[sheet], handler)
+	}
+
+	return ReadSheets(read, func(sheet string) string {
+		return filepath.Join(fileName, csvFiles[sheet])
+	})
+}
+
+// ReadSheets skips rows with broken values, they are reported by GetProblems with the file named by source
+func ReadSheets(read SheetReader, source func(sheet string) string) (schema.Database, error) {
 	db := NewDatabase()
 
-	err := readCsv(fileName, "rates.csv", db.AddRate)
+	err := read(RatesSheet, db.skipHeader(source(RatesSheet), db.AddRate))
 	if err != nil {
 		return nil, err
 	}
 
-	err = readCsv(fileName, "structure.csv", db.AddAccountMap)
+	err = read(AccountPlansSheet, db.skipHeader(source(AccountPlansSheet), db.AddAccountMap))
 	if err != nil {
 		return nil, err
 	}
 
-	err = readCsv(fileName, "accounts.csv", db.AddAccount)
+	err = read(AccountsSheet, db.skipHeader(source(AccountsSheet), db.AddAccount))
 	if err != nil {
 		return nil, err
 	}
 
-	err = readCsv(fileName, "txs.csv", db.AddTx)
+	err = read(TransactionsSheet, db.withTxHeader(source(TransactionsSheet), db.AddTx))
 	if err != nil {
 		return nil, err
 	}
 
+	db.rescale()
+
 	return db, nil
 }
 
-func readCsv(dirName string, fileName string, handler func([]string)) error {
+func (d *Database) skipHeader(file string, handler func([]string) error) func([]string) error {
+	row := 0
+
+	return func(record []string) error {
+		if row++; row == 1 {
+			return nil
+		}
+
+		if err := handler(record); err != nil {
+			d.Add(file, fmt.Sprintf("row %d", row), err)
+		}
+
+		return nil
+	}
+}
+
+func (d *Database) withTxHeader(file string, handler func(Record) error) func([]string) error {
+	var l *layout
+	row := 0
+
+	return func(values []string) (err error) {
+		if row++; l == nil {
+			if l, err = parseTxHeader(values); err != nil {
+				return schema.Locate(file, "header", err)
+			}
+			return
+		}
+
+		if err = handler(Record{values: values, layout: l}); err != nil {
+			d.Add(file, fmt.Sprintf("row %d", row), err)
+		}
+
+		return nil
+	}
+}
+
+func readCsv(dirName string, fileName string, handler func([]string) error) error {
 	file, err := os.Open(filepath.Join(dirName, fileName))
 
 	if err != nil {
@@ -45,7 +113,6 @@ func readCsv(dirName string, fileName string, handler func([]string)) error {
 	defer file.Close()
 
 	reader := csv.NewReader(file)
-	_, _ = reader.Read() // skip header
 
 	for {
 		record, err := reader.Read()
@@ -57,7 +124,9 @@ func readCsv(dirName string, fileName string, handler func([]string)) error {
 			return err
 		}
 
-		handler(record)
+		if err = handler(record); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,108 @@
+package csv_schema
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+// describe renders "date note [tags] account amount currency = balance, ..." for a transaction
+func describe(tx ledger.Transaction) string {
+	parts := make([]string, 0, len(tx.Items))
+
+	for _, item := range tx.Items {
+		part := item.Account + " " + item.Amount.String() + " " + item.Currency
+		if item.HasRunningBalance {
+			part += " = " + item.RunningBalance.String()
+		}
+		parts = append(parts, part)
+	}
+
+	flags := ""
+	if tx.Executed {
+		flags += "+"
+	}
+	if tx.Cleared {
+		flags += "*"
+	}
+
+	return tx.Date.Format("2006-01-02") + flags + " " + tx.Note + " [" + strings.Join(tx.Tags, " ") + "] " + strings.Join(parts, ", ")
+}
+
+func TestReadRussianHeaders(t *testing.T) {
+	db, err := ReadDatabase(filepath.Join("testdata", "ru"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := db.(*Database)
+
+	want := []string{
+		`2011-02-01+* продукты [Расходы\Еда Дача] Активы\Наличные -100.10 RUB = 899.90`,
+		`2011-02-03  [] Активы\Наличные 1000.00 RUB = 1899.90, Активы\Карта -1000.00 RUB = 4000.00`,
+	}
+
+	if len(d.Transactions) != len(want) {
+		t.Fatalf("got %d transactions, want %d", len(d.Transactions), len(want))
+	}
+
+	for i, tx := range d.Transactions {
+		if got := describe(tx); got != want[i] {
+			t.Errorf("transaction %d = %s, want %s", i, got, want[i])
+		}
+	}
+
+	if len(d.Scheduled) != 1 || d.Scheduled[0].Period.Ledger() != "monthly from 2011-02-02" {
+		t.Errorf("got scheduled %+v, want the monthly salary", d.Scheduled)
+	}
+
+	if accounts := *d.GetAccounts(); len(accounts) != 2 || accounts[0].Name != `Активы\Наличные` || accounts[0].InitBalance.String() != "1000.00" {
+		t.Errorf("got accounts %+v", accounts)
+	}
+
+	if rates := *d.GetRates(); len(rates) != 1 || rates[0].Currency1 != "USD" || rates[0].Amount2.String() != "30.5" {
+		t.Errorf("got rates %+v", rates)
+	}
+
+	if problems := d.GetProblems(); len(problems) != 1 || !strings.Contains(problems[0].Error(), "row 5") {
+		t.Errorf("got problems %v, want the invalid date in row 5", problems)
+	}
+}
+
+func TestParseTxHeader(t *testing.T) {
+	required := "Executed,Locked,Date,Income account,Income amount,Expense account,Expense amount,Comment"
+
+	tests := []struct {
+		header      string
+		classifiers []string
+		err         string
+	}{
+		{required, nil, ""},
+		{strings.ToUpper(required) + ", Category of Agent ,Категория Проект", []string{"Agent", "Проект"}, ""},
+		{"Исполнено,Заблокировано,Дата,Счет прихода,Сумма прихода,Счет расхода,Сумма расхода,Комментарий,Баланс прихода", nil, ""},
+		{required + ",Notes", nil, `unknown column "Notes"`},
+		{"Executed,Locked,Date,Income account,Income amount,Expense account,Expense amount", nil, `column "comment" is missing`},
+	}
+
+	for _, tt := range tests {
+		l, err := parseTxHeader(strings.Split(tt.header, ","))
+
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("header %q: got error %v, want %q", tt.header, err, tt.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("header %q: %v", tt.header, err)
+			continue
+		}
+
+		if strings.Join(l.classifiers, ",") != strings.Join(tt.classifiers, ",") {
+			t.Errorf("header %q: classifiers %q, want %q", tt.header, l.classifiers, tt.classifiers)
+		}
+	}
+}
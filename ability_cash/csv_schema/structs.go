@@ -1,7 +1,8 @@
 package csv_schema
 
 import (
-	"log"
+	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -10,18 +11,25 @@ import (
 	"github.com/Bishop/abilitycash2ledger/ledger"
 )
 
-// 0        1      2    3              4             5              6               7              8               9
-// Executed,Locked,Date,Income account,Income amount,Income balance,Expense account,Expense amount,Expense balance,Comment,
-// 10                   11                   12
-// Category of Category,Category of Provider,Category of Agent
-// 10         11           12       13                 14                    15
-// Recurrence,Day of month,Interval,Category of Category,Category of Provider,Category of Agent
+var isoCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// currencyPrecisions lists ISO 4217 currencies without cents
+var currencyPrecisions = map[string]uint{
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0, "PYG": 0, "UGX": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "JOD": 3, "TND": 3, "IQD": 3, "LYD": 3,
+}
 
 type Database struct {
+	schema.Report
 	Rates        []schema.Rate
 	Accounts     []schema.Account
 	AccountsMap  schema.AccountsMap
 	Transactions []ledger.Transaction
+	Scheduled    []ledger.Transaction
+	precisions   map[string]uint
+	currencies   []string
+	classifiers  []string
+	filled       map[string]bool
 }
 
 func NewDatabase() *Database {
@@ -31,50 +39,98 @@ func NewDatabase() *Database {
 	db.Accounts = make([]schema.Account, 0)
 	db.AccountsMap = make(schema.AccountsMap)
 	db.Transactions = make([]ledger.Transaction, 0)
+	db.Scheduled = make([]ledger.Transaction, 0)
+	db.precisions = make(map[string]uint)
+	db.filled = make(map[string]bool)
 
 	return db
 }
 
-func (d *Database) AddTx(record []string) {
+func (d *Database) AddTx(record Record) error {
+	date, err := parseDate(colDate, record.Get(colDate))
+	if err != nil {
+		return err
+	}
+
 	tx := ledger.Transaction{
-		Date:     parseDate(record[2]),
-		Note:     record[9],
-		Executed: record[0] == "+",
-		Cleared:  record[1] == "+",
+		Date:     date,
+		Note:     record.Get(colComment),
+		Executed: record.Get(colExecuted) == "+",
+		Cleared:  record.Get(colLocked) == "+",
 		Tags:     make([]string, 0),
 		Items:    []ledger.TxItem{},
 	}
 
-	for _, category := range record[10:] {
-		if category != "" {
-			tx.Tags = append(tx.Tags, category[1:])
+	for _, category := range record.Categories() {
+		tx.Tags = append(tx.Tags, strings.TrimPrefix(category, "\\"))
+	}
+
+	d.classifiers = record.layout.classifiers
+	for i, column := range record.layout.categories {
+		if column < len(record.values) && record.values[column] != "" {
+			d.filled[d.classifiers[i]] = true
+		}
+	}
+
+	sides := [][3]string{
+		{colIncomeAccount, colIncomeAmount, colIncomeBalance},
+		{colExpenseAccount, colExpenseAmount, colExpenseBalance},
+	}
+
+	for _, columns := range sides {
+		if record.Get(columns[0]) == "" {
+			continue
 		}
+
+		item, err := d.txItem(record, columns[0], columns[1], columns[2])
+		if err != nil {
+			return err
+		}
+
+		tx.Items = append(tx.Items, item)
 	}
 
-	if record[3] != "" {
-		tx.Items = append(tx.Items, d.txItemFromStrings(record[3], record[4]))
+	if tx.Period, err = parsePeriod(record, date); err != nil {
+		return err
 	}
 
-	if record[6] != "" {
-		tx.Items = append(tx.Items, d.txItemFromStrings(record[6], record[7]))
+	for _, item := range tx.Items {
+		d.useCurrency(item.Currency, item.Amount)
+		d.useCurrency(item.Currency, item.RunningBalance)
+	}
+
+	// a row with a recurrence is the schedule itself, executed occurrences are separate rows
+	if tx.Period != nil {
+		d.Scheduled = append(d.Scheduled, tx)
+	} else {
+		d.Transactions = append(d.Transactions, tx)
 	}
 
-	d.Transactions = append(d.Transactions, tx)
+	return nil
 }
 
-func (d *Database) AddRate(record []string) {
+func (d *Database) AddRate(record []string) (err error) {
 	rate := schema.Rate{
-		Date:      parseDate(record[0]),
 		Currency1: record[1],
 		Currency2: record[3],
-		Amount1:   parseFloat(record[2]),
-		Amount2:   parseFloat(record[4]),
+	}
+
+	if rate.Date, err = parseDate("date", record[0]); err != nil {
+		return
+	}
+	if rate.Amount1, err = parseAmount("amount 1", record[2]); err != nil {
+		return
+	}
+	if rate.Amount2, err = parseAmount("amount 2", record[4]); err != nil {
+		return
 	}
 
 	d.Rates = append(d.Rates, rate)
+
+	return nil
 }
 
-func (d *Database) AddAccountMap(record []string) {
+func (d *Database) AddAccountMap(record []string) error {
 	dir := strings.Replace(record[0], "\\Root", "", 1)
 	dir = strings.Replace(dir, "\\", "", 1)
 
@@ -85,16 +141,26 @@ func (d *Database) AddAccountMap(record []string) {
 	}
 
 	d.AccountsMap[record[1]] = account
+
+	return nil
 }
 
-func (d *Database) AddAccount(record []string) {
+func (d *Database) AddAccount(record []string) error {
+	balance, err := parseAmount("init balance", record[2])
+	if err != nil {
+		return err
+	}
+
 	account := schema.Account{
 		Name:        d.account(record[0]),
 		Currency:    record[1],
-		InitBalance: parseFloat(record[2]),
+		InitBalance: balance,
 	}
 
 	d.Accounts = append(d.Accounts, account)
+	d.useCurrency(account.Currency, account.InitBalance)
+
+	return nil
 }
 
 func (d *Database) GetAccounts() *[]schema.Account {
@@ -109,6 +175,92 @@ func (d *Database) GetRates() *[]schema.Rate {
 	return &d.Rates
 }
 
+func (d *Database) GetScheduled() []ledger.Transaction {
+	return d.Scheduled
+}
+
+// GetClassifiers names empty category columns after the header, roots of filled ones come with the values
+func (d *Database) GetClassifiers() []string {
+	names := make([]string, 0)
+
+	for _, classifier := range d.classifiers {
+		if !d.filled[classifier] {
+			names = append(names, classifier)
+		}
+	}
+
+	return names
+}
+
+// GetCurrencies has no source table in CSV, so the precision is the ISO one or the longest fraction seen in amounts
+func (d *Database) GetCurrencies() *[]schema.Currency {
+	currencies := make([]schema.Currency, len(d.currencies))
+
+	for i, code := range d.currencies {
+		currencies[i] = schema.Currency{
+			Code:      code,
+			Name:      code,
+			Precision: d.precisions[code],
+		}
+	}
+
+	return &currencies
+}
+
+func (d *Database) useCurrency(code string, amount ledger.Amount) {
+	precision, ok := d.precisions[code]
+
+	if !ok {
+		d.currencies = append(d.currencies, code)
+		precision = defaultPrecision(code)
+		d.precisions[code] = precision
+	}
+
+	if amount.Precision() > precision {
+		d.precisions[code] = amount.Precision()
+	}
+}
+
+// rescale pads amounts to the currency precision, which is known when all rows are read
+func (d *Database) rescale() {
+	for i, account := range d.Accounts {
+		d.Accounts[i].InitBalance = pad(account.InitBalance, d.precisions[account.Currency])
+	}
+
+	for _, txs := range [][]ledger.Transaction{d.Transactions, d.Scheduled} {
+		for _, tx := range txs {
+			for i, item := range tx.Items {
+				precision := d.precisions[item.Currency]
+
+				tx.Items[i].Amount = pad(item.Amount, precision)
+				tx.Items[i].RunningBalance = pad(item.RunningBalance, precision)
+			}
+		}
+	}
+}
+
+// pad keeps a value too large to be padded as it is
+func pad(amount ledger.Amount, precision uint) ledger.Amount {
+	if padded, err := amount.Rescale(precision); err == nil {
+		return padded
+	}
+
+	return amount
+}
+
+// defaultPrecision is the ISO 4217 minor unit, amounts in CSV lose trailing zeros
+func defaultPrecision(code string) uint {
+	if precision, ok := currencyPrecisions[code]; ok {
+		return precision
+	}
+
+	if isoCode.MatchString(code) {
+		return 2
+	}
+
+	return 0
+}
+
 func (d *Database) account(a string) string {
 	account, ok := d.AccountsMap[a]
 	if ok {
@@ -118,34 +270,95 @@ func (d *Database) account(a string) string {
 	}
 }
 
-func (d *Database) txItemFromStrings(accountString, amountString string) ledger.TxItem {
-	accountParts := strings.SplitN(accountString, " - ", 2)
+// txItem reads an account like "USD - Wallet" with its amount and the optional running balance
+func (d *Database) txItem(record Record, accountColumn, amountColumn, balanceColumn string) (item ledger.TxItem, err error) {
+	currency, account, ok := strings.Cut(record.Get(accountColumn), " - ")
+	if !ok {
+		return item, schema.FieldError(accountColumn, fmt.Errorf("invalid account %q, expected \"currency - name\"", record.Get(accountColumn)))
+	}
+
+	item.Account, item.Currency = d.account(account), currency
+
+	if item.Amount, err = parseAmount(amountColumn, record.Get(amountColumn)); err != nil {
+		return
+	}
 
-	return ledger.TxItem{
-		Account:  d.account(accountParts[1]),
-		Currency: accountParts[0],
-		Amount:   parseFloat(amountString),
+	if balance := record.Get(balanceColumn); balance != "" {
+		if item.RunningBalance, err = parseAmount(balanceColumn, balance); err != nil {
+			return
+		}
+		item.HasRunningBalance = true
 	}
+
+	return
 }
 
-func parseDate(s string) time.Time {
+func parseDate(field, s string) (time.Time, error) {
 	const format = "2006-01-02" // 2011-01-01
 
 	parse, err := time.ParseInLocation(format, s, time.Local)
 
 	if err != nil {
-		log.Fatalln(err)
+		return parse, schema.FieldError(field, fmt.Errorf("invalid date %q", s))
 	}
 
-	return parse
+	return parse, nil
 }
 
-func parseFloat(s string) float64 {
-	amount, err := strconv.ParseFloat(s, 64)
+func parseAmount(field, s string) (ledger.Amount, error) {
+	amount, err := ledger.ParseAmount(s)
 
 	if err != nil {
-		log.Fatalln(err)
+		return amount, schema.FieldError(field, err)
 	}
 
-	return amount
+	return amount, nil
+}
+
+// parsePeriod returns nil for a row without a recurrence, the day of month moves the first occurrence
+func parsePeriod(record Record, date time.Time) (*ledger.Period, error) {
+	recurrence := strings.ToLower(strings.TrimSpace(record.Get(colRecurrence)))
+
+	unit, ok := recurrences[recurrence]
+	if !ok {
+		return nil, schema.FieldError(colRecurrence, fmt.Errorf("unknown recurrence %q", record.Get(colRecurrence)))
+	}
+
+	if unit == "" {
+		return nil, nil
+	}
+
+	period := &ledger.Period{Unit: unit, Interval: 1, Start: date}
+
+	if s := record.Get(colInterval); s != "" {
+		interval, err := strconv.Atoi(s)
+		if err != nil || interval < 1 {
+			return nil, schema.FieldError(colInterval, fmt.Errorf("invalid interval %q", s))
+		}
+		period.Interval = interval
+	}
+
+	if s := record.Get(colDayOfMonth); s != "" && unit == ledger.Monthly {
+		day, err := strconv.Atoi(s)
+		if err != nil || day < 1 || day > 31 {
+			return nil, schema.FieldError(colDayOfMonth, fmt.Errorf("invalid day of month %q", s))
+		}
+
+		period.Start = dayOfMonth(date, day)
+		if period.Start.Day() < date.Day() {
+			period.Start = dayOfMonth(date.AddDate(0, 0, 1-date.Day()).AddDate(0, 1, 0), day)
+		}
+	}
+
+	return period, nil
+}
+
+// dayOfMonth is the day of the month of date, a day past the end of the month is its last day
+func dayOfMonth(date time.Time, day int) time.Time {
+	last := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.Local).Day()
+	if day > last {
+		day = last
+	}
+
+	return time.Date(date.Year(), date.Month(), day, 0, 0, 0, 0, time.Local)
 }
@@ -0,0 +1,41 @@
+package csv_schema
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePeriodDayOfMonth(t *testing.T) {
+	l := &layout{columns: map[string]int{colRecurrence: 0, colDayOfMonth: 1}}
+	day := func(s string) time.Time {
+		date, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return date
+	}
+
+	tests := []struct {
+		date string
+		day  string
+		want string
+	}{
+		{"2022-01-10", "15", "2022-01-15"},
+		{"2022-01-20", "15", "2022-02-15"},
+		{"2022-04-10", "31", "2022-04-30"},
+		{"2022-01-31", "30", "2022-02-28"},
+		{"2022-02-10", "30", "2022-02-28"},
+		{"2024-02-10", "31", "2024-02-29"},
+		{"2022-02-28", "31", "2022-02-28"},
+		{"2022-12-20", "5", "2023-01-05"},
+		{"2022-12-31", "30", "2023-01-30"},
+	}
+
+	for _, tt := range tests {
+		period, err := parsePeriod(Record{values: []string{"Monthly", tt.day}, layout: l}, day(tt.date))
+
+		if err != nil || period == nil || period.Start.Format("2006-01-02") != tt.want {
+			t.Errorf("day %s from %s = %v, %v; want %s", tt.day, tt.date, period, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package ability_cash
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+// MergedDatabase joins databases covering overlapping periods, like yearly exports and the current database
+type MergedDatabase struct {
+	Duplicates   int
+	Conflicts    []Conflict
+	accounts     []schema.Account
+	rates        []schema.Rate
+	currencies   []schema.Currency
+	classifiers  []string
+	transactions []ledger.Transaction
+	scheduled    []ledger.Transaction
+	problems     []error
+}
+
+// Conflict is a transaction changed between datafiles, the later datafile wins
+type Conflict struct {
+	Kept    ledger.Transaction
+	Dropped ledger.Transaction
+}
+
+// MergeDatabases sorts transactions by date and drops duplicates: the ones with a known source id, or the ones
+// from another database whose every row is already there with the same date, postings and comment
+func MergeDatabases(dbs []schema.Database) (*MergedDatabase, error) {
+	m := new(MergedDatabase)
+
+	ids := make(map[string]int)
+	seen := make(map[string]int)
+	accounts := make(map[string]bool)
+	currencies := make(map[string]bool)
+	rates := make(map[string]bool)
+	classifiers := make(map[string]bool)
+	scheduled := make(map[string]bool)
+
+	for _, db := range dbs {
+		for _, account := range *db.GetAccounts() {
+			if !accounts[account.Name] {
+				accounts[account.Name] = true
+				m.accounts = append(m.accounts, account)
+			}
+		}
+
+		for _, currency := range *db.GetCurrencies() {
+			if !currencies[currency.Code] {
+				currencies[currency.Code] = true
+				m.currencies = append(m.currencies, currency)
+			}
+		}
+
+		for _, rate := range *db.GetRates() {
+			key := fmt.Sprintf("%s %s %s %s %s", rate.Date.Format("2006-01-02"), rate.Currency1, rate.Amount1.Normalize(), rate.Currency2, rate.Amount2.Normalize())
+
+			if !rates[key] {
+				rates[key] = true
+				m.rates = append(m.rates, rate)
+			}
+		}
+
+		if source, ok := db.(schema.ClassifiersSource); ok {
+			for _, name := range source.GetClassifiers() {
+				if !classifiers[name] {
+					classifiers[name] = true
+					m.classifiers = append(m.classifiers, name)
+				}
+			}
+		}
+
+		if source, ok := db.(schema.ScheduleSource); ok {
+			for _, tx := range source.GetScheduled() {
+				key := fmt.Sprintf("%s|%s", tx.Period, fingerprint(tx))
+
+				if !scheduled[key] {
+					scheduled[key] = true
+					m.scheduled = append(m.scheduled, tx)
+				}
+			}
+		}
+
+		// ids are only comparable between databases of the same format
+		format := fmt.Sprintf("%T", db)
+		occurrences := make(map[string]int)
+
+		err := schema.EachTransaction(db, func(tx ledger.Transaction) error {
+			sum := fingerprint(tx)
+			rows := rowFingerprints(tx)
+			duplicate := true
+
+			for _, row := range rows {
+				occurrences[row]++
+				duplicate = duplicate && occurrences[row] <= seen[row]
+			}
+
+			if tx.Id != "" {
+				key := format + " " + tx.Id
+
+				if i, ok := ids[key]; ok {
+					if fingerprint(m.transactions[i]) != sum {
+						m.Conflicts = append(m.Conflicts, Conflict{Kept: tx, Dropped: m.transactions[i]})
+						m.transactions[i] = tx
+					} else {
+						m.Duplicates++
+					}
+
+					return nil
+				}
+			}
+
+			if duplicate && len(rows) > 0 {
+				m.Duplicates++
+				return nil
+			}
+
+			if tx.Id != "" {
+				ids[format+" "+tx.Id] = len(m.transactions)
+			}
+
+			m.transactions = append(m.transactions, tx)
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		m.problems = append(m.problems, schema.Problems(db)...)
+
+		for sum, count := range occurrences {
+			if count > seen[sum] {
+				seen[sum] = count
+			}
+		}
+	}
+
+	sort.SliceStable(m.transactions, func(a, b int) bool {
+		return m.transactions[a].Date.Before(m.transactions[b].Date)
+	})
+
+	return m, nil
+}
+
+// fingerprint identifies a transaction without a source id by its rows
+func fingerprint(tx ledger.Transaction) string {
+	return strings.Join(rowFingerprints(tx), "\n")
+}
+
+// rowFingerprints identify the source rows of a transaction: the date, the postings and the comment. An XML export
+// keeps a transaction per row and accounts with their folders, SQLite joins the rows of a group into one transaction
+// with a posting id on the first posting of every row, so accounts are compared by the leaf name and the postings
+// added by the reader to balance a row are left out.
+func rowFingerprints(tx ledger.Transaction) []string {
+	rows := make([]string, 0, 1)
+	items := make([]string, 0, len(tx.Items))
+	note := tx.Note
+
+	flush := func() {
+		if len(items) > 0 {
+			sort.Strings(items)
+			rows = append(rows, fmt.Sprintf("%s|%s|%s", tx.Date.Format("2006-01-02"), strings.Join(items, "|"), note))
+		}
+		items = items[:0]
+	}
+
+	for i, item := range tx.Items {
+		if item.Account == ledger.Unknown {
+			continue
+		}
+
+		if item.Id != "" && i > 0 {
+			flush()
+
+			note = tx.Note
+			if item.Note != "" {
+				note = item.Note
+			}
+		}
+
+		items = append(items, fmt.Sprintf("%s %s %s", leafAccount(item.Account), item.Amount.Normalize(), item.Currency))
+	}
+
+	flush()
+
+	return rows
+}
+
+func leafAccount(account string) string {
+	return account[strings.LastIndexAny(account, "\\:")+1:]
+}
+
+func (m *MergedDatabase) GetAccounts() *[]schema.Account {
+	return &m.accounts
+}
+
+func (m *MergedDatabase) GetTransactions() *[]ledger.Transaction {
+	return &m.transactions
+}
+
+func (m *MergedDatabase) GetRates() *[]schema.Rate {
+	return &m.rates
+}
+
+func (m *MergedDatabase) GetCurrencies() *[]schema.Currency {
+	return &m.currencies
+}
+
+func (m *MergedDatabase) GetClassifiers() []string {
+	return m.classifiers
+}
+
+func (m *MergedDatabase) GetScheduled() []ledger.Transaction {
+	return m.scheduled
+}
+
+func (m *MergedDatabase) GetProblems() []error {
+	return m.problems
+}
@@ -0,0 +1,112 @@
+package ability_cash
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+	"github.com/Bishop/abilitycash2ledger/ability_cash/sql_schema"
+	"github.com/Bishop/abilitycash2ledger/ability_cash/xml_schema"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+// overlapDatabases reads testdata/overlap.xml and a SQLite database built from testdata/overlap.sql
+func overlapDatabases(t *testing.T) (schema.Database, schema.Database) {
+	t.Helper()
+
+	xmlDb, err := xml_schema.ReadDatabase(filepath.Join("testdata", "overlap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script, err := os.ReadFile(filepath.Join("testdata", "overlap.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "overlap.cash")
+
+	base, err := sql.Open("sqlite3", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer base.Close()
+
+	if _, err = base.Exec(string(script)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, day := range []string{"2011-02-01", "2011-02-02", "2011-02-03", "2011-02-10"} {
+		date, _ := time.ParseInLocation("2006-01-02", day, time.Local)
+
+		if _, err = base.Exec("UPDATE Transactions SET HolderDateTime = ? WHERE HolderDateTime = ?", date.Unix(), day); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sqlDb, err := sql_schema.ReadDatabase(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return xmlDb, sqlDb
+}
+
+func TestMergeAcrossFormats(t *testing.T) {
+	xmlDb, sqlDb := overlapDatabases(t)
+
+	tests := []struct {
+		name       string
+		dbs        []schema.Database
+		duplicates int
+		notes      []string
+	}{
+		{"yearly export first", []schema.Database{xmlDb, sqlDb}, 3, []string{"receipt", "soap", "", "to cash", "new"}},
+		{"current database first", []schema.Database{sqlDb, xmlDb}, 4, []string{"receipt", "", "to cash", "new"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := MergeDatabases(tt.dbs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			notes := make([]string, 0)
+			for _, tx := range *merged.GetTransactions() {
+				notes = append(notes, tx.Note)
+			}
+
+			if merged.Duplicates != tt.duplicates || !equalLines(notes, tt.notes) || len(merged.Conflicts) > 0 {
+				t.Errorf("got %d duplicates, %d conflicts, %q; want %d duplicates, %q",
+					merged.Duplicates, len(merged.Conflicts), notes, tt.duplicates, tt.notes)
+			}
+		})
+	}
+}
+
+func TestRowFingerprints(t *testing.T) {
+	day := time.Date(2011, 2, 1, 0, 0, 0, 0, time.Local)
+
+	split := ledger.Transaction{Date: day, Note: "receipt", Items: []ledger.TxItem{
+		{Id: "1", Account: "Cash", Currency: "RUB", Amount: ledger.NewAmount(-10010, 2)},
+		{Account: ledger.Unknown, Currency: "RUB", Amount: ledger.NewAmount(10010, 2)},
+		{Id: "2", Account: "Cash", Currency: "RUB", Amount: ledger.NewAmount(-50, 0), Note: "soap"},
+	}}
+	first := ledger.Transaction{Date: day, Note: "receipt", Items: []ledger.TxItem{
+		{Account: "Assets\\Cash", Currency: "RUB", Amount: ledger.NewAmount(-1001, 1)},
+	}}
+	second := ledger.Transaction{Date: day, Note: "soap", Items: []ledger.TxItem{
+		{Account: "Assets\\Cash", Currency: "RUB", Amount: ledger.NewAmount(-5000, 2)},
+	}}
+
+	rows := rowFingerprints(split)
+	want := []string{rowFingerprints(first)[0], rowFingerprints(second)[0]}
+
+	if !equalLines(rows, want) {
+		t.Errorf("split rows %q, want %q", rows, want)
+	}
+}
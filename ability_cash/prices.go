@@ -0,0 +1,83 @@
+package ability_cash
+
+import (
+	"fmt"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+// addPrice derives the rate of an exchange: a unit of the received currency in the paid one
+func (c *LedgerConverter) addPrice(tx ledger.Transaction) {
+	received, paid, ok := exchangePostings(&tx)
+	if !ok {
+		return
+	}
+
+	source := fmt.Sprintf("exchange %s -> %s", paid.Account, received.Account)
+	if tx.Id != "" {
+		source = fmt.Sprintf("%s, %s: %s", source, IdTag, tx.Id)
+	}
+
+	c.prices = append(c.prices, schema.Rate{
+		Date:      tx.Date,
+		Currency1: received.Currency,
+		Currency2: paid.Currency,
+		Amount1:   received.Amount,
+		Amount2:   paid.Amount.Neg(),
+		Source:    source,
+	})
+}
+
+// Rates joins the rates table with the prices of exchanges, call it after Transactions. A derived price is dropped
+// when the table has a rate of the same pair on that day, or another exchange of the day gave the same price.
+// The result is normalized to the base currency and has only the currencies of transactions.
+func (c *LedgerConverter) Rates() []schema.Rate {
+	rates := append([]schema.Rate{}, *c.Db.GetRates()...)
+	known := make(map[string]bool)
+
+	for _, rate := range rates {
+		known[ratePair(rate)] = true
+	}
+
+	for _, rate := range c.prices {
+		amount, err := rate.Amount2.Quo(rate.Amount1, costPrecision)
+		price := fmt.Sprintf("%s %s", ratePair(rate), amount.Normalize())
+
+		if err != nil || known[ratePair(rate)] || known[price] {
+			continue
+		}
+
+		known[price] = true
+		rates = append(rates, rate)
+	}
+
+	var used map[string]bool
+
+	if c.currencies != nil {
+		used = map[string]bool{c.BaseCurrency: true}
+		for currency := range c.currencies {
+			used[currency] = true
+		}
+	}
+
+	return NormalizeRates(rates, c.BaseCurrency, used)
+}
+
+func (c *LedgerConverter) useCurrencies(tx ledger.Transaction) {
+	for _, item := range tx.Items {
+		if item.Currency != "" {
+			c.currencies[item.Currency] = true
+		}
+	}
+}
+
+// ratePair names the currencies of a rate on its day in either direction
+func ratePair(rate schema.Rate) string {
+	first, second := rate.Currency1, rate.Currency2
+	if first > second {
+		first, second = second, first
+	}
+
+	return fmt.Sprintf("%s %s %s", rate.Date.Format("2006-01-02"), first, second)
+}
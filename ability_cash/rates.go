@@ -0,0 +1,123 @@
+package ability_cash
+
+import (
+	"sort"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+type rateKey struct {
+	date      string
+	currency1 string
+	currency2 string
+}
+
+// NormalizeRates turns every rate into the price of one unit: of a currency in the base one, when the base is set.
+// A rate in the base currency inverts, a cross rate goes through the latest base rate of its quote currency
+// and stays as it is without one. One rate per pair and day is left, whichever its direction: a direct one over
+// a cross one, then the last one. Rates of currencies missing in used are dropped, unless used is nil.
+func NormalizeRates(rates []schema.Rate, base string, used map[string]bool) []schema.Rate {
+	normalized := make([]schema.Rate, 0, len(rates))
+	cross := make([]schema.Rate, 0)
+
+	for _, rate := range rates {
+		if rate.Amount1.IsZero() || rate.Amount2.IsZero() || rate.Currency1 == rate.Currency2 {
+			continue
+		}
+
+		if rate.Currency1 == base {
+			rate = schema.Rate{
+				Date:      rate.Date,
+				Currency1: rate.Currency2,
+				Currency2: rate.Currency1,
+				Amount1:   rate.Amount2,
+				Amount2:   rate.Amount1,
+				Source:    rate.Source,
+			}
+		}
+
+		price, err := rate.Amount2.Quo(rate.Amount1, costPrecision)
+		if err != nil {
+			continue
+		}
+
+		rate.Amount1, rate.Amount2 = ledger.NewAmount(1, 0), price.Normalize()
+
+		if base != "" && rate.Currency2 != base {
+			cross = append(cross, rate)
+		} else {
+			normalized = append(normalized, rate)
+		}
+	}
+
+	direct := len(normalized)
+	sortRates(normalized)
+
+	for _, rate := range cross {
+		if quote, ok := latestRate(normalized[:direct], rate.Currency2, rate); ok {
+			price, err := rate.Amount2.Mul(quote.Amount2)
+			if err != nil {
+				continue
+			}
+
+			rate.Currency2, rate.Amount2 = base, price.Round(costPrecision).Normalize()
+		}
+
+		normalized = append(normalized, rate)
+	}
+
+	index := make(map[rateKey]int)
+	crossed := make(map[rateKey]bool)
+	result := make([]schema.Rate, 0, len(normalized))
+
+	for i, rate := range normalized {
+		if used != nil && (!used[rate.Currency1] || !used[rate.Currency2]) {
+			continue
+		}
+
+		key := rateKey{rate.Date.Format("2006-01-02"), rate.Currency1, rate.Currency2}
+		if key.currency1 > key.currency2 {
+			key.currency1, key.currency2 = key.currency2, key.currency1
+		}
+
+		// a direct rate of the day is not replaced by a cross one
+		if k, ok := index[key]; ok {
+			if i < direct || crossed[key] {
+				result[k], crossed[key] = rate, i >= direct
+			}
+			continue
+		}
+
+		index[key], crossed[key] = len(result), i >= direct
+		result = append(result, rate)
+	}
+
+	sortRates(result)
+
+	return result
+}
+
+// latestRate finds the rate of the currency in the base one on the day of the rate or before
+func latestRate(rates []schema.Rate, currency string, rate schema.Rate) (schema.Rate, bool) {
+	var found schema.Rate
+	ok := false
+
+	for _, r := range rates {
+		if r.Date.After(rate.Date) {
+			break
+		}
+
+		if r.Currency1 == currency {
+			found, ok = r, true
+		}
+	}
+
+	return found, ok
+}
+
+func sortRates(rates []schema.Rate) {
+	sort.SliceStable(rates, func(a, b int) bool {
+		return rates[a].Date.Before(rates[b].Date)
+	})
+}
@@ -0,0 +1,115 @@
+package ability_cash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+)
+
+func rate(day, amount1, currency1, amount2, currency2 string) schema.Rate {
+	date, err := time.ParseInLocation("2006-01-02", day, time.Local)
+	if err != nil {
+		panic(err)
+	}
+
+	return schema.Rate{
+		Date:      date,
+		Currency1: currency1,
+		Amount1:   item("", amount1, "").Amount,
+		Currency2: currency2,
+		Amount2:   item("", amount2, "").Amount,
+	}
+}
+
+// prices renders "date currency price quote" lines
+func prices(rates []schema.Rate) []string {
+	lines := make([]string, len(rates))
+
+	for i, r := range rates {
+		lines[i] = r.Date.Format("2006-01-02") + " " + r.Currency1 + " " + r.Amount2.String() + " " + r.Currency2
+		if r.Amount1.String() != "1" {
+			lines[i] += " per " + r.Amount1.String()
+		}
+	}
+
+	return lines
+}
+
+func TestNormalizeRates(t *testing.T) {
+	rates := []schema.Rate{
+		rate("2011-01-01", "1000", "RUB", "32", "USD"),
+		rate("2011-01-01", "100", "JPY", "0.7", "USD"),
+		rate("2011-01-02", "100", "JPY", "25", "RUB"),
+		rate("2011-01-02", "100", "JPY", "0.8", "USD"),
+		rate("2011-01-03", "1", "USD", "30", "RUB"),
+		rate("2011-01-03", "1", "USD", "31", "RUB"),
+		rate("2011-01-03", "1", "EUR", "40", "RUB"),
+		rate("2011-01-04", "0", "USD", "31", "RUB"),
+		rate("2011-01-04", "1", "RUB", "1", "RUB"),
+	}
+
+	tests := []struct {
+		name string
+		base string
+		used map[string]bool
+		want []string
+	}{
+		{
+			name: "price of one unit",
+			want: []string{
+				"2011-01-01 RUB 0.032 USD",
+				"2011-01-01 JPY 0.007 USD",
+				"2011-01-02 JPY 0.25 RUB",
+				"2011-01-02 JPY 0.008 USD",
+				"2011-01-03 USD 31 RUB",
+				"2011-01-03 EUR 40 RUB",
+			},
+		},
+		{
+			name: "inverse and cross rates in the base currency",
+			base: "RUB",
+			used: map[string]bool{"RUB": true, "USD": true, "JPY": true},
+			want: []string{
+				"2011-01-01 USD 31.25 RUB",
+				"2011-01-01 JPY 0.21875 RUB",
+				"2011-01-02 JPY 0.25 RUB",
+				"2011-01-03 USD 31 RUB",
+			},
+		},
+		{
+			name: "cross rate without a base rate",
+			base: "GBP",
+			used: map[string]bool{"RUB": true, "USD": true},
+			want: []string{"2011-01-01 RUB 0.032 USD", "2011-01-03 USD 31 RUB"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prices(NormalizeRates(rates, tt.base, tt.used)); !equalLines(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeInversePairs(t *testing.T) {
+	rates := []schema.Rate{
+		rate("2011-01-01", "1000", "RUB", "32", "USD"),
+		rate("2011-01-01", "1", "USD", "31", "RUB"),
+		rate("2011-01-02", "1", "USD", "30", "RUB"),
+		rate("2011-01-02", "100", "RUB", "3.2", "USD"),
+		rate("2011-01-02", "1", "EUR", "40", "RUB"),
+	}
+
+	want := []string{
+		"2011-01-01 USD 31 RUB",
+		"2011-01-02 RUB 0.032 USD",
+		"2011-01-02 EUR 40 RUB",
+	}
+
+	if got := prices(NormalizeRates(rates, "", nil)); !equalLines(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+package ability_cash
+
+import (
+	"fmt"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+// Divergence is the first transaction where the computed balance of an account differs from the source one
+type Divergence struct {
+	Account  string
+	Currency string
+	Tx       ledger.Transaction
+	Expected ledger.Amount
+	Actual   ledger.Amount
+}
+
+type balanceKey struct {
+	account  string
+	currency string
+}
+
+// Reconcile sums converted postings per account and compares the sums with the running balances of the source.
+// The running balances include the initial ones, the opening postings are counted first when the transactions
+// do not have them. A sum which does not fit an amount stops it, the rest of the transactions are drained.
+func Reconcile(txs <-chan ledger.Transaction, opening []ledger.TxItem) ([]Divergence, error) {
+	balances := make(map[balanceKey]ledger.Amount)
+	diverged := make(map[balanceKey]bool)
+	divergences := make([]Divergence, 0)
+	failed := addBalances(balances, opening)
+
+	for tx := range txs {
+		if failed != nil {
+			continue
+		}
+
+		postings, err := postingAmounts(tx.Items, balances)
+		if err == nil {
+			err = addBalances(balances, postings)
+		}
+
+		if err != nil {
+			failed = fmt.Errorf("%s %s: %w", tx.Date.Format("2006-01-02"), tx.Payee, err)
+			continue
+		}
+
+		for _, posting := range postings {
+			key := balanceKey{posting.Account, posting.Currency}
+
+			if !posting.HasRunningBalance || diverged[key] || balances[key].Cmp(posting.RunningBalance) == 0 {
+				continue
+			}
+
+			diverged[key] = true
+			divergences = append(divergences, Divergence{
+				Account:  posting.Account,
+				Currency: posting.Currency,
+				Tx:       tx,
+				Expected: posting.RunningBalance,
+				Actual:   balances[key],
+			})
+		}
+	}
+
+	return divergences, failed
+}
+
+func addBalances(balances map[balanceKey]ledger.Amount, postings []ledger.TxItem) error {
+	for _, posting := range postings {
+		key := balanceKey{posting.Account, posting.Currency}
+
+		sum, err := balances[key].Add(posting.Amount)
+		if err != nil {
+			return err
+		}
+
+		balances[key] = sum
+	}
+
+	return nil
+}
+
+// postingAmounts fills the amounts ledger would calculate: balance assignments and the elided posting
+func postingAmounts(items []ledger.TxItem, balances map[balanceKey]ledger.Amount) ([]ledger.TxItem, error) {
+	postings := make([]ledger.TxItem, len(items))
+	elided := -1
+
+	for i, item := range items {
+		postings[i] = item
+
+		switch {
+		case !item.Amount.IsZero():
+		case item.HasBalanceAssertion && !item.HasRunningBalance:
+			amount, err := item.BalanceAssertion.Sub(balances[balanceKey{item.Account, item.Currency}])
+			if err != nil {
+				return nil, err
+			}
+			postings[i].Amount = amount
+		default:
+			elided = i
+		}
+	}
+
+	rest, err := remainder(postings)
+	if err != nil {
+		return nil, err
+	}
+
+	if elided < 0 || len(rest) != 1 {
+		return postings, nil
+	}
+
+	for currency, amount := range rest {
+		postings[elided].Amount, postings[elided].Currency = amount.Neg(), currency
+	}
+
+	return postings, nil
+}
@@ -0,0 +1,58 @@
+package ability_cash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+type testDatabase struct {
+	accounts []schema.Account
+	txs      []ledger.Transaction
+}
+
+func (d *testDatabase) GetAccounts() *[]schema.Account         { return &d.accounts }
+func (d *testDatabase) GetTransactions() *[]ledger.Transaction { return &d.txs }
+func (d *testDatabase) GetRates() *[]schema.Rate               { return &[]schema.Rate{} }
+func (d *testDatabase) GetCurrencies() *[]schema.Currency      { return &[]schema.Currency{} }
+
+func TestReconcileInitBalance(t *testing.T) {
+	db := &testDatabase{
+		accounts: []schema.Account{{Name: "Cash", Currency: "RUB", InitBalance: item("", "1000", "").Amount}},
+		txs: []ledger.Transaction{{
+			Date:  time.Date(2011, 2, 1, 0, 0, 0, 0, time.Local),
+			Items: []ledger.TxItem{balanced(item("Cash", "-100", "RUB", "Expenses\\Food"), "900")},
+		}},
+	}
+
+	tests := []struct {
+		name        string
+		equity      bool
+		seed        bool
+		divergences int
+	}{
+		{"opening transaction", true, false, 0},
+		{"opening balances counted apart", false, true, 0},
+		{"no opening balances", false, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := testConverter()
+			converter.Db, converter.GenerateEquity = db, tt.equity
+
+			var opening []ledger.TxItem
+			if tt.seed {
+				opening = converter.OpeningBalance().Items
+			}
+
+			divergences, err := Reconcile(converter.Transactions(), opening)
+
+			if err != nil || converter.Err() != nil || len(divergences) != tt.divergences {
+				t.Errorf("got %+v, %v, %v; want %d divergences", divergences, err, converter.Err(), tt.divergences)
+			}
+		})
+	}
+}
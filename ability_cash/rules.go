@@ -0,0 +1,208 @@
+package ability_cash
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+// Rule rewrites converted transactions. Empty conditions match anything, accounts and categories are matched
+// as colon separated paths (Assets:Cash, Expenses:Food). Target replaces the account of postings matching
+// Account, or of the balancing postings (categories, fallback) if Account is empty; it may refer to the groups
+// of Account, like $1.
+type Rule struct {
+	Account  string            `json:"account,omitempty"`
+	Category string            `json:"category,omitempty"`
+	Comment  string            `json:"comment,omitempty"`
+	Currency string            `json:"currency,omitempty"`
+	Min      *ledger.Amount    `json:"min,omitempty"`
+	Max      *ledger.Amount    `json:"max,omitempty"`
+	Target   string            `json:"target,omitempty"`
+	Payee    string            `json:"payee,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	account  *regexp.Regexp
+	category *regexp.Regexp
+	comment  *regexp.Regexp
+}
+
+func DefaultRules() []*Rule {
+	return []*Rule{
+		{Account: "^Assets:(.+)$", Target: "$1"},
+	}
+}
+
+// CompileRules prepares the expressions of the rules, call it once before the rules are shared by converters
+func CompileRules(rules []*Rule) error {
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Rule) compile() (err error) {
+	if r.account, err = compile(r.Account); err != nil {
+		return
+	}
+
+	if r.category, err = compile(r.Category); err != nil {
+		return
+	}
+
+	r.comment, err = compile(r.Comment)
+
+	return
+}
+
+func compile(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile(expr)
+}
+
+// matchTx checks the transaction level conditions
+func (r *Rule) matchTx(tx *ledger.Transaction, categories []string) bool {
+	if r.comment != nil && !r.comment.MatchString(tx.Note) {
+		return false
+	}
+
+	if r.category != nil && !anyMatch(r.category, categories) {
+		return false
+	}
+
+	if r.Currency != "" || r.Min != nil || r.Max != nil {
+		amount, ok := largest(tx.Items, r.Currency)
+
+		if !ok || r.Min != nil && amount.Cmp(*r.Min) < 0 || r.Max != nil && amount.Cmp(*r.Max) > 0 {
+			return false
+		}
+	}
+
+	if r.account == nil {
+		return true
+	}
+
+	for _, item := range tx.Items {
+		if r.account.MatchString(item.Account) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Rule) target(account string, counter bool) (string, bool) {
+	switch {
+	case r.Target == "":
+		return account, false
+	case r.account == nil && counter:
+		return r.Target, true
+	case r.account == nil:
+		return account, false
+	}
+
+	// the target replaces the whole account, not the matched part of it
+	match := r.account.FindStringSubmatchIndex(account)
+	if match == nil {
+		return account, false
+	}
+
+	return string(r.account.ExpandString(nil, r.Target, account, match)), true
+}
+
+// applyRules runs every matching rule: the first one sets the account of a posting, the first payee wins,
+// tags and metadata are collected
+func (c *LedgerConverter) applyRules(tx *ledger.Transaction, counter []bool, categories []string) {
+	targeted := make([]bool, len(tx.Items))
+	sources := make([]string, len(tx.Items))
+	payee := false
+
+	for i := range tx.Items {
+		sources[i] = tx.Items[i].Account
+	}
+
+	for _, rule := range c.Rules {
+		if !rule.matchTx(tx, categories) {
+			continue
+		}
+
+		for i := range tx.Items {
+			if targeted[i] {
+				continue
+			}
+
+			tx.Items[i].Account, targeted[i] = rule.target(tx.Items[i].Account, counter[i])
+		}
+
+		if rule.Payee != "" && !payee {
+			tx.Payee, payee = rule.Payee, true
+		}
+
+		for _, tag := range rule.Tags {
+			tx.Tags = appendUnique(tx.Tags, tag)
+		}
+
+		for key, value := range rule.Metadata {
+			if tx.Metadata == nil {
+				tx.Metadata = make(map[string]string)
+			}
+			if _, ok := tx.Metadata[key]; !ok {
+				tx.Metadata[key] = value
+			}
+		}
+	}
+
+	for i := range tx.Items {
+		tx.Items[i].Account = c.register(tx.Items[i].Account, sources[i])
+	}
+}
+
+func anyMatch(expr *regexp.Regexp, list []string) bool {
+	for _, s := range list {
+		if expr.MatchString(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// largest returns the biggest absolute amount of the transaction, in the currency if it is given
+func largest(items []ledger.TxItem, currency string) (ledger.Amount, bool) {
+	var result ledger.Amount
+	found := false
+
+	for _, item := range items {
+		if item.Amount.IsZero() || currency != "" && item.Currency != currency {
+			continue
+		}
+
+		if amount := item.Amount.Abs(); !found || amount.Cmp(result) > 0 {
+			result, found = amount, true
+		}
+	}
+
+	return result, found
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+
+	return append(list, s)
+}
+
+func path(s string) string {
+	return strings.Replace(s, "\\", ":", -1)
+}
@@ -0,0 +1,39 @@
+package ability_cash
+
+import "testing"
+
+func TestRuleTarget(t *testing.T) {
+	tests := []struct {
+		rule    Rule
+		account string
+		counter bool
+		want    string
+		ok      bool
+	}{
+		{Rule{Account: "^Assets:(.+)$", Target: "$1"}, "Assets:Cash", false, "Cash", true},
+		{Rule{Account: "^Assets:(.+)$", Target: "$1"}, "Liabilities:Card", false, "Liabilities:Card", false},
+		{Rule{Account: "Cash", Target: "Assets:Wallet"}, "Assets:Cash", false, "Assets:Wallet", true},
+		{Rule{Account: "Cash", Target: "Assets:Wallet"}, "Cash:Box", false, "Assets:Wallet", true},
+		{Rule{Account: "^(\\w+):Cash$", Target: "$1:Wallet"}, "Assets:Cash", false, "Assets:Wallet", true},
+		{Rule{Target: "Expenses:Taxi"}, "Expenses:Transport", true, "Expenses:Taxi", true},
+		{Rule{Target: "Expenses:Taxi"}, "Assets:Cash", false, "Assets:Cash", false},
+		{Rule{Account: "Cash"}, "Assets:Cash", false, "Assets:Cash", false},
+	}
+
+	for _, tt := range tests {
+		rule := tt.rule
+		if err := CompileRules([]*Rule{&rule}); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, ok := rule.target(tt.account, tt.counter); got != tt.want || ok != tt.ok {
+			t.Errorf("%q -> %q on %s = %s, %v; want %s, %v", tt.rule.Account, tt.rule.Target, tt.account, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCompileRules(t *testing.T) {
+	if err := CompileRules([]*Rule{{Account: "Cash"}, {Comment: "("}}); err == nil {
+		t.Error("want an error for a broken expression")
+	}
+}
@@ -0,0 +1,81 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+)
+
+// ReadError points to the value of a datafile which could not be read
+type ReadError struct {
+	File     string
+	Location string
+	Field    string
+	Err      error
+}
+
+func (e *ReadError) Error() string {
+	parts := make([]string, 0, 4)
+
+	for _, part := range []string{e.File, e.Location, e.Field} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(append(parts, e.Err.Error()), ": ")
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// FieldError is returned by value parsers, the reader adds the file and the location
+func FieldError(field string, err error) error {
+	return &ReadError{Field: field, Err: err}
+}
+
+// Locate fills the file and the location of a ReadError, other errors are wrapped into one
+func Locate(file, location string, err error) error {
+	located := &ReadError{Err: err}
+
+	var readErr *ReadError
+	if errors.As(err, &readErr) {
+		copied := *readErr
+		located = &copied
+	}
+
+	if located.File == "" {
+		located.File = file
+	}
+	if located.Location == "" {
+		located.Location = location
+	}
+
+	return located
+}
+
+// ProblemsSource is implemented by databases which skip broken records instead of failing
+type ProblemsSource interface {
+	GetProblems() []error
+}
+
+// Report collects the problems of skipped records
+type Report struct {
+	problems []error
+}
+
+func (r *Report) Add(file, location string, err error) {
+	r.problems = append(r.problems, Locate(file, location, err))
+}
+
+func (r *Report) GetProblems() []error {
+	return r.problems
+}
+
+func Problems(db Database) []error {
+	if source, ok := db.(ProblemsSource); ok {
+		return source.GetProblems()
+	}
+
+	return nil
+}
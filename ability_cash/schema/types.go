@@ -10,20 +10,58 @@ type Database interface {
 	GetAccounts() *[]Account
 	GetTransactions() *[]ledger.Transaction
 	GetRates() *[]Rate
+	GetCurrencies() *[]Currency
+}
+
+// TransactionsStream is implemented by databases which do not keep transactions in memory
+type TransactionsStream interface {
+	EachTransaction(callback func(ledger.Transaction) error) error
+}
+
+// ClassifiersSource is implemented by databases which declare category roots, used or not
+type ClassifiersSource interface {
+	GetClassifiers() []string
+}
+
+// ScheduleSource is implemented by databases which keep scheduled transactions, they have a Period
+type ScheduleSource interface {
+	GetScheduled() []ledger.Transaction
+}
+
+func EachTransaction(db Database, callback func(ledger.Transaction) error) error {
+	if stream, ok := db.(TransactionsStream); ok {
+		return stream.EachTransaction(callback)
+	}
+
+	for _, tx := range *db.GetTransactions() {
+		if err := callback(tx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type Currency struct {
+	Code      string
+	Name      string
+	Precision uint
 }
 
 type Account struct {
 	Name        string
 	Currency    string
-	InitBalance float64
+	InitBalance ledger.Amount
 }
 
 type Rate struct {
 	Date      time.Time
 	Currency1 string
 	Currency2 string
-	Amount1   float64
-	Amount2   float64
+	Amount1   ledger.Amount
+	Amount2   ledger.Amount
+	// Source tells where a rate missing in the rates table came from
+	Source string
 }
 
 type AccountsMap map[string]string
@@ -24,16 +24,26 @@ SELECT * FROM parents
 	RatesSql        = "SELECT RateDate, Currency1, Currency2, Value1, Value2 FROM CurrencyRates WHERE NOT Deleted ORDER BY RateDate"
 	TxCategoriesSql = "SELECT Category, \"Transaction\" FROM TransactionCategories WHERE NOT Deleted"
 	TxsSql          = `
-    SELECT tx.Id, HolderDateTime, Locked, IncomeAccount, IncomeAmount, ExpenseAccount, ExpenseAmount, Comment
+    SELECT tx.Id, tx."Group", HolderDateTime, Locked, IncomeAccount, IncomeAmount, ExpenseAccount, ExpenseAmount, Comment
       FROM Transactions tx
 INNER JOIN TransactionGroups txg ON tx."Group" = txg.Id
      WHERE NOT tx.Deleted AND Executed
-  ORDER BY HolderDateTime, txg.Position
+  ORDER BY HolderDateTime, txg.Position, tx.Id
 `
+	SchedulesSql    = `SELECT "Group", Recurrence, Interval, StartDate FROM Schedules WHERE NOT Deleted`
+	ScheduledTxsSql = `
+    SELECT tx.Id, tx."Group", HolderDateTime, Locked, IncomeAccount, IncomeAmount, ExpenseAccount, ExpenseAmount, Comment
+      FROM Transactions tx
+INNER JOIN Schedules s ON tx."Group" = s."Group"
+     WHERE NOT tx.Deleted AND NOT s.Deleted AND NOT Executed
+  ORDER BY tx."Group", tx.Id
+`
+	TableSql = "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
 )
 
 func ReadDatabase(fileName string) (schema.Database, error) {
 	db := NewDatabase()
+	db.fileName = fileName
 
 	base, err := sql.Open("sqlite3", fileName)
 	if err != nil {
@@ -42,32 +52,52 @@ func ReadDatabase(fileName string) (schema.Database, error) {
 
 	err = query(CurrenciesSql, base, db.readCurrencies)
 	if err != nil {
-		return nil, err
+		return nil, schema.Locate(fileName, "Currencies", err)
 	}
 
 	err = query(RatesSql, base, db.readRates)
 	if err != nil {
-		return nil, err
+		return nil, schema.Locate(fileName, "CurrencyRates", err)
 	}
 
 	err = query(AccountsSql, base, db.readAccounts)
 	if err != nil {
-		return nil, err
+		return nil, schema.Locate(fileName, "Accounts", err)
 	}
 
 	err = query(CategoriesSql, base, db.readCategories)
 	if err != nil {
-		return nil, err
+		return nil, schema.Locate(fileName, "Categories", err)
 	}
 
 	err = query(TxCategoriesSql, base, db.readTxCategories)
 	if err != nil {
-		return nil, err
+		return nil, schema.Locate(fileName, "TransactionCategories", err)
 	}
 
 	err = query(TxsSql, base, db.readTxs)
 	if err != nil {
-		return nil, err
+		return nil, schema.Locate(fileName, "Transactions", err)
+	}
+
+	// datafiles without schedules may have no table for them
+	var schedules int
+	if err = base.QueryRow(TableSql, "Schedules").Scan(&schedules); err != nil {
+		return nil, schema.Locate(fileName, "Schedules", err)
+	}
+
+	if schedules == 0 {
+		return db, nil
+	}
+
+	err = query(SchedulesSql, base, db.readSchedules)
+	if err != nil {
+		return nil, schema.Locate(fileName, "Schedules", err)
+	}
+
+	err = query(ScheduledTxsSql, base, db.readScheduledTxs)
+	if err != nil {
+		return nil, schema.Locate(fileName, "Transactions", err)
 	}
 
 	return db, nil
@@ -0,0 +1,125 @@
+package sql_schema
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+const testSchema = `
+CREATE TABLE Currencies(Id INTEGER PRIMARY KEY, Code TEXT, Name TEXT, Precision INTEGER, Deleted INTEGER DEFAULT 0);
+CREATE TABLE CurrencyRates(Id INTEGER PRIMARY KEY, RateDate INTEGER, Currency1 INTEGER, Currency2 INTEGER, Value1 INTEGER, Value2 INTEGER, Deleted INTEGER DEFAULT 0);
+CREATE TABLE Accounts(Id INTEGER PRIMARY KEY, Name TEXT, StartingBalance INTEGER, Currency INTEGER, Deleted INTEGER DEFAULT 0);
+CREATE TABLE Categories(Id INTEGER PRIMARY KEY, Name TEXT, Parent INTEGER, Deleted INTEGER DEFAULT 0);
+CREATE TABLE TransactionCategories(Id INTEGER PRIMARY KEY, Category INTEGER, "Transaction" INTEGER, Deleted INTEGER DEFAULT 0);
+CREATE TABLE TransactionGroups(Id INTEGER PRIMARY KEY, Position INTEGER);
+CREATE TABLE Transactions(Id INTEGER PRIMARY KEY, "Group" INTEGER, HolderDateTime INTEGER, Locked INTEGER, Executed INTEGER, IncomeAccount INTEGER, IncomeAmount INTEGER, ExpenseAccount INTEGER, ExpenseAmount INTEGER, Comment TEXT, Deleted INTEGER DEFAULT 0);
+
+INSERT INTO Currencies VALUES (1, 'RUB', 'Ruble', 2, 0);
+INSERT INTO Accounts VALUES (1, 'Cash', 0, 1, 0);
+INSERT INTO Categories VALUES (1, 'Expenses', NULL, 0), (2, 'Food', 1, 0);
+INSERT INTO TransactionGroups VALUES (1, 1), (2, 2), (3, 3);
+INSERT INTO Transactions VALUES
+    (1, 1, 0, 0, 1, NULL, NULL, 1, -1000000, 'executed', 0),
+    (2, 2, 0, 0, 0, NULL, NULL, 1, -1000000, 'food', 0),
+    (3, 2, 0, 0, 0, NULL, NULL, 1, -500000, 'more food', 0),
+    (4, 3, 0, 0, 0, NULL, NULL, 1, -700000, 'planned once', 0);
+INSERT INTO TransactionCategories (Category, "Transaction") VALUES (2, 2);
+`
+
+func testDatabase(t *testing.T, script string) *Database {
+	t.Helper()
+
+	fileName := filepath.Join(t.TempDir(), "test.cash")
+
+	base, err := sql.Open("sqlite3", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer base.Close()
+
+	if _, err = base.Exec(script); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := ReadDatabase(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db.(*Database)
+}
+
+func TestReadWithoutSchedules(t *testing.T) {
+	db := testDatabase(t, testSchema)
+
+	if len(db.Transactions) != 1 || len(db.GetScheduled()) != 0 {
+		t.Errorf("got %d transactions and %d scheduled, want 1 and 0", len(db.Transactions), len(db.GetScheduled()))
+	}
+}
+
+func TestReadScheduled(t *testing.T) {
+	start := time.Date(2022, 1, 15, 0, 0, 0, 0, time.Local).Unix()
+
+	db := testDatabase(t, testSchema+fmt.Sprintf(`
+CREATE TABLE Schedules(Id INTEGER PRIMARY KEY, "Group" INTEGER, Recurrence INTEGER, Interval INTEGER, StartDate INTEGER, Deleted INTEGER DEFAULT 0);
+INSERT INTO Schedules ("Group", Recurrence, Interval, StartDate) VALUES (2, 2, 1, %d), (3, 9, 1, 0);
+`, start))
+
+	scheduled := db.GetScheduled()
+
+	if len(db.Transactions) != 1 || len(scheduled) != 1 {
+		t.Fatalf("got %d transactions and %d scheduled, want 1 and 1", len(db.Transactions), len(scheduled))
+	}
+
+	tx := scheduled[0]
+
+	if tx.Period == nil || tx.Period.String() != "every 15th day of month from 2022-01-15" {
+		t.Errorf("period = %v, want the 15th day of month", tx.Period)
+	}
+
+	want := []struct {
+		account string
+		amount  string
+	}{
+		{"Cash", "-100"},
+		{"Cash", "-50"},
+		{ledger.Unknown, "50"},
+	}
+
+	if len(tx.Items) != len(want) {
+		t.Fatalf("got postings %+v, want %d", tx.Items, len(want))
+	}
+
+	for i, w := range want {
+		if item := tx.Items[i]; item.Account != w.account || item.Amount.Normalize().String() != w.amount {
+			t.Errorf("posting %d = %s %s, want %s %s", i, item.Account, item.Amount, w.account, w.amount)
+		}
+	}
+
+	if problems := db.GetProblems(); len(problems) != 1 {
+		t.Errorf("got problems %v, want the unknown recurrence", problems)
+	}
+}
+
+func TestReadUnknownCurrency(t *testing.T) {
+	db := testDatabase(t, testSchema+`
+INSERT INTO Currencies VALUES (2, 'USD', 'Dollar', 2, 1);
+INSERT INTO CurrencyRates VALUES (1, 0, 1, 2, 100, 3000, 0), (2, 0, 1, 1, 100, 100, 0);
+INSERT INTO Accounts VALUES (2, 'Wallet', 0, 2, 0);
+INSERT INTO TransactionGroups VALUES (4, 4);
+INSERT INTO Transactions VALUES (5, 4, 0, 0, 1, 2, 1000000, NULL, NULL, 'deleted currency', 0);
+`)
+
+	if len(db.Rates) != 1 || len(db.Accounts) != 1 || len(db.Transactions) != 1 {
+		t.Errorf("got %d rates, %d accounts, %d transactions; want 1 of each", len(db.Rates), len(db.Accounts), len(db.Transactions))
+	}
+
+	if problems := db.GetProblems(); len(problems) != 3 {
+		t.Errorf("got problems %v, want the rate, the account and the transaction", problems)
+	}
+}
@@ -2,7 +2,10 @@ package sql_schema
 
 import (
 	"database/sql"
+	"fmt"
 	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
@@ -10,19 +13,34 @@ import (
 )
 
 type Database struct {
+	schema.Report
 	Rates             []schema.Rate
 	Accounts          []schema.Account
+	Currencies        []schema.Currency
 	Transactions      []ledger.Transaction
+	Scheduled         []ledger.Transaction
 	accountIndex      map[int]*schema.Account
 	currenciesIndexI  map[int]*Currency
 	currenciesIndexS  map[string]*Currency
 	categoriesIndex   map[int]string
 	txCategoriesIndex map[int][]int
+	groupsIndex       map[int]int
+	schedules         map[int]*ledger.Period
+	scheduledIndex    map[int]int
+	fileName          string
+}
+
+// recurrences maps the Recurrence column of Schedules to period units
+var recurrences = map[int]string{
+	0: ledger.Daily,
+	1: ledger.Weekly,
+	2: ledger.Monthly,
+	3: ledger.Yearly,
 }
 
 type Currency struct {
 	Code      string
-	Precision float64
+	Precision uint
 }
 
 type FetchFunc func(dest ...any) error
@@ -32,13 +50,18 @@ func NewDatabase() *Database {
 
 	db.Rates = make([]schema.Rate, 0)
 	db.Accounts = make([]schema.Account, 0)
+	db.Currencies = make([]schema.Currency, 0)
 	db.Transactions = make([]ledger.Transaction, 0)
+	db.Scheduled = make([]ledger.Transaction, 0)
 
 	db.accountIndex = make(map[int]*schema.Account)
 	db.currenciesIndexI = make(map[int]*Currency)
 	db.currenciesIndexS = make(map[string]*Currency)
 	db.categoriesIndex = make(map[int]string)
 	db.txCategoriesIndex = make(map[int][]int)
+	db.groupsIndex = make(map[int]int)
+	db.schedules = make(map[int]*ledger.Period)
+	db.scheduledIndex = make(map[int]int)
 
 	return db
 }
@@ -55,6 +78,26 @@ func (d *Database) GetRates() *[]schema.Rate {
 	return &d.Rates
 }
 
+func (d *Database) GetCurrencies() *[]schema.Currency {
+	return &d.Currencies
+}
+
+func (d *Database) GetScheduled() []ledger.Transaction {
+	return d.Scheduled
+}
+
+func (d *Database) GetClassifiers() []string {
+	names := make([]string, 0)
+
+	for _, name := range d.categoriesIndex {
+		if !strings.Contains(name, "\\") {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
 func (d *Database) readCurrencies(uid int, fetch FetchFunc) error {
 	currency := Currency{}
 
@@ -66,6 +109,12 @@ func (d *Database) readCurrencies(uid int, fetch FetchFunc) error {
 	d.currenciesIndexI[uid] = &currency
 	d.currenciesIndexS[currency.Code] = &currency
 
+	d.Currencies = append(d.Currencies, schema.Currency{
+		Code:      currency.Code,
+		Name:      currency.Code,
+		Precision: currency.Precision,
+	})
+
 	return nil
 }
 
@@ -79,12 +128,24 @@ func (d *Database) readRates(uid int, fetch FetchFunc) error {
 		return err
 	}
 
+	c1, err := d.currency(currency1)
+	if err != nil {
+		d.Add(d.fileName, fmt.Sprintf("rate %d", uid), err)
+		return nil
+	}
+
+	c2, err := d.currency(currency2)
+	if err != nil {
+		d.Add(d.fileName, fmt.Sprintf("rate %d", uid), err)
+		return nil
+	}
+
 	d.Rates = append(d.Rates, schema.Rate{
 		Date:      time.Unix(date, 0),
-		Currency1: d.currency(currency1).Code,
-		Currency2: d.currency(currency2).Code,
-		Amount1:   d.currency(currency1).ConvertAmount(value1),
-		Amount2:   d.currency(currency2).ConvertAmount(value2),
+		Currency1: c1.Code,
+		Currency2: c2.Code,
+		Amount1:   c1.ConvertRate(value1),
+		Amount2:   c2.ConvertRate(value2),
 	})
 
 	return nil
@@ -92,15 +153,23 @@ func (d *Database) readRates(uid int, fetch FetchFunc) error {
 
 func (d *Database) readAccounts(uid int, fetch FetchFunc) error {
 	var currencyId int
+	var balance float64
 	account := schema.Account{}
 
-	err := fetch(&uid, &account.Name, &account.InitBalance, &currencyId)
+	err := fetch(&uid, &account.Name, &balance, &currencyId)
 	if err != nil {
 		return err
 	}
 
-	account.Currency = d.currency(currencyId).Code
-	account.InitBalance = d.currency(currencyId).ConvertAmount(account.InitBalance)
+	// accounts of deleted currencies are not read, so their transactions are reported and skipped
+	currency, err := d.currency(currencyId)
+	if err != nil {
+		d.Add(d.fileName, fmt.Sprintf("account %q", account.Name), err)
+		return nil
+	}
+
+	account.Currency = currency.Code
+	account.InitBalance = currency.ConvertAmount(balance)
 
 	d.Accounts = append(d.Accounts, account)
 	d.accountIndex[uid] = &account
@@ -144,18 +213,96 @@ func (d *Database) readTxCategories(uid int, fetch FetchFunc) error {
 }
 
 func (d *Database) readTxs(uid int, fetch FetchFunc) error {
+	tx, group, err := d.readRow(uid, fetch)
+	if err != nil || tx == nil {
+		return err
+	}
+
+	d.Transactions = d.addRow(d.Transactions, d.groupsIndex, group, *tx)
+
+	return nil
+}
+
+func (d *Database) readSchedules(uid int, fetch FetchFunc) error {
+	var group, recurrence, interval int
+	var start int64
+
+	err := fetch(&group, &recurrence, &interval, &start)
+	if err != nil {
+		return err
+	}
+
+	unit, ok := recurrences[recurrence]
+	if !ok {
+		err = schema.FieldError("recurrence", fmt.Errorf("unknown recurrence %d", recurrence))
+		d.Add(d.fileName, fmt.Sprintf("schedule of group %d", group), err)
+		return nil
+	}
+
+	if interval < 1 {
+		interval = 1
+	}
+
+	d.schedules[group] = &ledger.Period{Unit: unit, Interval: interval, Start: time.Unix(start, 0)}
+
+	return nil
+}
+
+// readScheduledTxs reads the rows of scheduled groups, they are never executed themselves
+func (d *Database) readScheduledTxs(uid int, fetch FetchFunc) error {
+	tx, group, err := d.readRow(uid, fetch)
+	if err != nil || tx == nil {
+		return err
+	}
+
+	period, ok := d.schedules[group]
+	if !ok {
+		return nil
+	}
+
+	tx.Period = period
+	d.Scheduled = d.addRow(d.Scheduled, d.scheduledIndex, group, *tx)
+
+	return nil
+}
+
+// addRow appends a transaction or joins it to the transaction of its group as a split
+func (d *Database) addRow(txs []ledger.Transaction, groups map[int]int, group int, tx ledger.Transaction) []ledger.Transaction {
+	if index, ok := groups[group]; ok {
+		d.addSplit(&txs[index], tx)
+		return txs
+	}
+
+	groups[group] = len(txs)
+
+	return append(txs, tx)
+}
+
+// readRow returns nil for a row which is skipped and reported
+func (d *Database) readRow(uid int, fetch FetchFunc) (*ledger.Transaction, int, error) {
 	var iaccout, eaccount sql.NullInt32
 	var iamount, eamount sql.NullFloat64
+	var group int
 	var date int64
 	var locked bool
 	var comment string
 
-	err := fetch(&uid, &date, &locked, &iaccout, &iamount, &eaccount, &eamount, &comment)
+	err := fetch(&uid, &group, &date, &locked, &iaccout, &iamount, &eaccount, &eamount, &comment)
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+
+	// deleted accounts are not read, so their transactions are reported and skipped
+	for _, account := range []sql.NullInt32{iaccout, eaccount} {
+		if _, ok := d.accountIndex[int(account.Int32)]; account.Valid && !ok {
+			err = schema.FieldError("account", fmt.Errorf("unknown account id %d", account.Int32))
+			d.Add(d.fileName, fmt.Sprintf("transaction %d", uid), err)
+			return nil, 0, nil
+		}
 	}
 
 	tx := ledger.Transaction{
+		Id:      strconv.Itoa(uid),
 		Date:    time.Unix(date, 0),
 		Note:    comment,
 		Cleared: locked,
@@ -163,12 +310,21 @@ func (d *Database) readTxs(uid int, fetch FetchFunc) error {
 		Items:   []ledger.TxItem{},
 	}
 
-	if iaccout.Valid {
-		tx.Items = append(tx.Items, d.makeTxItem(iaccout, iamount))
-	}
+	for _, row := range []struct {
+		account sql.NullInt32
+		amount  sql.NullFloat64
+	}{{iaccout, iamount}, {eaccount, eamount}} {
+		if !row.account.Valid {
+			continue
+		}
+
+		item, err := d.makeTxItem(row.account, row.amount)
+		if err != nil {
+			d.Add(d.fileName, fmt.Sprintf("transaction %d", uid), err)
+			return nil, 0, nil
+		}
 
-	if eaccount.Valid {
-		tx.Items = append(tx.Items, d.makeTxItem(eaccount, eamount))
+		tx.Items = append(tx.Items, item)
 	}
 
 	if categories, ok := d.txCategoriesIndex[uid]; ok {
@@ -177,32 +333,86 @@ func (d *Database) readTxs(uid int, fetch FetchFunc) error {
 		}
 	}
 
-	d.Transactions = append(d.Transactions, tx)
+	return &tx, group, nil
+}
 
-	return nil
+// addSplit joins one more row of the group, categories and id of every row go to its first posting
+func (d *Database) addSplit(tx *ledger.Transaction, row ledger.Transaction) {
+	if len(tx.Items) > 0 && tx.Items[0].Id == "" {
+		tx.Items = balanceRow(tx.Items, tx.Tags)
+		tx.Items[0].Id = tx.Id
+		tx.Items[0].Tags, tx.Tags = tx.Tags, make([]string, 0)
+	}
+
+	if len(row.Items) == 0 {
+		return
+	}
+
+	row.Items[0].Tags = row.Tags
+	row.Items[0].Id = row.Id
+
+	if row.Note != tx.Note {
+		row.Items[0].Note = row.Note
+	}
+
+	tx.Items = append(tx.Items, balanceRow(row.Items, row.Tags)...)
+}
+
+// balanceRow sends an income or expense row without categories to the fallback account, as nothing else
+// balances it in a split transaction
+func balanceRow(items []ledger.TxItem, categories []string) []ledger.TxItem {
+	if len(categories) > 0 || len(items) != 1 {
+		return items
+	}
+
+	return append(items, ledger.TxItem{
+		Account:  ledger.Unknown,
+		Currency: items[0].Currency,
+		Amount:   items[0].Amount.Neg(),
+	})
 }
 
-func (d *Database) makeTxItem(accountId sql.NullInt32, amount sql.NullFloat64) ledger.TxItem {
+func (d *Database) makeTxItem(accountId sql.NullInt32, amount sql.NullFloat64) (ledger.TxItem, error) {
 	account := d.accountIndex[int(accountId.Int32)]
 
+	currency, err := d.currency(account.Currency)
+	if err != nil {
+		return ledger.TxItem{}, err
+	}
+
 	return ledger.TxItem{
 		Account:  account.Name,
 		Currency: account.Currency,
-		Amount:   d.currency(account.Currency).ConvertAmount(amount.Float64),
-	}
+		Amount:   currency.ConvertAmount(amount.Float64),
+	}, nil
 }
 
-func (d *Database) currency(id any) *Currency {
+// currency finds a currency by its id or code, deleted currencies are not read
+func (d *Database) currency(id any) (*Currency, error) {
+	var currency *Currency
+
 	switch v := id.(type) {
 	case int:
-		return d.currenciesIndexI[v]
+		currency = d.currenciesIndexI[v]
 	case string:
-		return d.currenciesIndexS[v]
+		currency = d.currenciesIndexS[v]
 	default:
-		panic("Unknown id type")
+		return nil, fmt.Errorf("unknown currency id type %T", id)
 	}
+
+	if currency == nil {
+		return nil, schema.FieldError("currency", fmt.Errorf("unknown currency %v", id))
+	}
+
+	return currency, nil
+}
+
+// ConvertAmount turns the stored integer (scaled by 10^(Precision+2)) into an amount of the currency precision
+func (c *Currency) ConvertAmount(amount float64) ledger.Amount {
+	return ledger.NewAmount(int64(math.Round(amount)), c.Precision+2).Round(c.Precision)
 }
 
-func (c *Currency) ConvertAmount(amount float64) float64 {
-	return amount / math.Pow(10, c.Precision+2)
+// ConvertRate keeps all stored digits, as rates are not limited by the currency precision
+func (c *Currency) ConvertRate(amount float64) ledger.Amount {
+	return ledger.NewAmount(int64(math.Round(amount)), c.Precision+2).Normalize()
 }
@@ -0,0 +1,53 @@
+package sql_schema
+
+import (
+	"testing"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+func TestAddSplitBalancesUncategorizedRows(t *testing.T) {
+	d := NewDatabase()
+
+	row := func(id, amount string, tags ...string) ledger.Transaction {
+		value, _ := ledger.ParseAmount(amount)
+
+		return ledger.Transaction{
+			Id:    id,
+			Tags:  tags,
+			Items: []ledger.TxItem{{Account: "Cash", Currency: "RUB", Amount: value}},
+		}
+	}
+
+	tx := row("1", "-30")
+	d.addSplit(&tx, row("2", "-20", "Expenses\\Food"))
+	d.addSplit(&tx, row("3", "-10"))
+
+	want := []struct {
+		account string
+		amount  string
+		id      string
+	}{
+		{"Cash", "-30", "1"},
+		{ledger.Unknown, "30", ""},
+		{"Cash", "-20", "2"},
+		{"Cash", "-10", "3"},
+		{ledger.Unknown, "10", ""},
+	}
+
+	if len(tx.Items) != len(want) {
+		t.Fatalf("got %d postings, want %d: %+v", len(tx.Items), len(want), tx.Items)
+	}
+
+	for i, w := range want {
+		item := tx.Items[i]
+
+		if item.Account != w.account || item.Amount.String() != w.amount || item.Id != w.id {
+			t.Errorf("posting %d = %s %s (id %q), want %s %s (id %q)", i, item.Account, item.Amount, item.Id, w.account, w.amount, w.id)
+		}
+	}
+
+	if len(tx.Items[2].Tags) != 1 || len(tx.Tags) != 0 {
+		t.Errorf("categories are not moved to the postings: %v, %v", tx.Items[2].Tags, tx.Tags)
+	}
+}
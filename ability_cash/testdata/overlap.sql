@@ -0,0 +1,22 @@
+-- the data of overlap.xml joined with one more transaction, receipt and soap are rows of a group
+CREATE TABLE Currencies(Id INTEGER PRIMARY KEY, Code TEXT, Name TEXT, Precision INTEGER, Deleted INTEGER DEFAULT 0);
+CREATE TABLE CurrencyRates(Id INTEGER PRIMARY KEY, RateDate INTEGER, Currency1 INTEGER, Currency2 INTEGER, Value1 INTEGER, Value2 INTEGER, Deleted INTEGER DEFAULT 0);
+CREATE TABLE Accounts(Id INTEGER PRIMARY KEY, Name TEXT, StartingBalance INTEGER, Currency INTEGER, Deleted INTEGER DEFAULT 0);
+CREATE TABLE Categories(Id INTEGER PRIMARY KEY, Name TEXT, Parent INTEGER, Deleted INTEGER DEFAULT 0);
+CREATE TABLE TransactionCategories(Id INTEGER PRIMARY KEY, Category INTEGER, "Transaction" INTEGER, Deleted INTEGER DEFAULT 0);
+CREATE TABLE TransactionGroups(Id INTEGER PRIMARY KEY, Position INTEGER);
+CREATE TABLE Transactions(Id INTEGER PRIMARY KEY, "Group" INTEGER, HolderDateTime INTEGER, Locked INTEGER, Executed INTEGER, IncomeAccount INTEGER, IncomeAmount INTEGER, ExpenseAccount INTEGER, ExpenseAmount INTEGER, Comment TEXT, Deleted INTEGER DEFAULT 0);
+
+INSERT INTO Currencies VALUES (1, 'RUB', 'Ruble', 2, 0);
+INSERT INTO Accounts VALUES (1, 'Cash', 10000000, 1, 0), (2, 'Card', 0, 1, 0);
+INSERT INTO Categories VALUES (1, 'Expenses', NULL, 0), (2, 'Food', 1, 0), (3, 'Home', 1, 0), (4, 'Income', NULL, 0), (5, 'Salary', 4, 0);
+INSERT INTO TransactionGroups VALUES (1, 1), (2, 2), (3, 3), (4, 4);
+
+-- dates are replaced with the local time stamps of the days
+INSERT INTO Transactions VALUES
+    (1, 1, '2011-02-01', 0, 1, NULL, NULL, 1, -1001000, 'receipt', 0),
+    (2, 1, '2011-02-01', 0, 1, NULL, NULL, 1, -500000, 'soap', 0),
+    (3, 2, '2011-02-02', 0, 1, 2, 50000000, NULL, NULL, '', 0),
+    (4, 3, '2011-02-03', 0, 1, 1, 10000000, 2, -10000000, 'to cash', 0),
+    (5, 4, '2011-02-10', 0, 1, NULL, NULL, 2, -300000, 'new', 0);
+INSERT INTO TransactionCategories (Category, "Transaction") VALUES (2, 1), (3, 2), (5, 3), (3, 5);
@@ -0,0 +1,22 @@
+package xlsx_schema
+
+import (
+	"fmt"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/csv_schema"
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+)
+
+// ReadDatabase reads the AbilityCash Excel export, the same sheets in2csv would split into CSV files
+func ReadDatabase(fileName string) (schema.Database, error) {
+	book, err := openWorkbook(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	defer book.Close()
+
+	return csv_schema.ReadSheets(book.ReadSheet, func(sheet string) string {
+		return fmt.Sprintf("%s [%s]", fileName, sheet)
+	})
+}
@@ -0,0 +1,282 @@
+package xlsx_schema
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type workbook struct {
+	archive *zip.ReadCloser
+	sheets  map[string]string
+	strings []string
+	dates   map[int]bool
+}
+
+type workbookXml struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		Id   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationshipsXml struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type sharedStringsXml struct {
+	Items []struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+type stylesXml struct {
+	NumberFormats []struct {
+		Id   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellFormats []struct {
+		NumberFormatId int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type rowXml struct {
+	Cells []struct {
+		Ref    string `xml:"r,attr"`
+		Type   string `xml:"t,attr"`
+		Style  int    `xml:"s,attr"`
+		Value  string `xml:"v"`
+		Inline string `xml:"is>t"`
+	} `xml:"c"`
+}
+
+func openWorkbook(fileName string) (*workbook, error) {
+	archive, err := zip.OpenReader(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &workbook{archive: archive, sheets: make(map[string]string), dates: make(map[int]bool)}
+
+	if err = w.readIndex(); err != nil {
+		_ = archive.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *workbook) Close() error {
+	return w.archive.Close()
+}
+
+func (w *workbook) ReadSheet(name string, handler func([]string) error) error {
+	target, ok := w.sheets[name]
+	if !ok {
+		return fmt.Errorf("sheet %q not found", name)
+	}
+
+	file, err := w.archive.Open(target)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	decoder := xml.NewDecoder(file)
+	width := 0
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+
+		row := new(rowXml)
+		if err = decoder.DecodeElement(row, &start); err != nil {
+			return err
+		}
+
+		record := w.record(row)
+
+		if width == 0 {
+			width = len(record)
+		}
+		for len(record) < width {
+			record = append(record, "")
+		}
+
+		if err = handler(record); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *workbook) record(row *rowXml) []string {
+	record := make([]string, 0, len(row.Cells))
+
+	for _, cell := range row.Cells {
+		if column := columnIndex(cell.Ref); column >= 0 {
+			for len(record) < column {
+				record = append(record, "")
+			}
+		}
+
+		var value string
+
+		switch cell.Type {
+		case "s":
+			if index, err := strconv.Atoi(cell.Value); err == nil && index < len(w.strings) {
+				value = w.strings[index]
+			}
+		case "inlineStr":
+			value = cell.Inline
+		case "b":
+			if cell.Value == "1" {
+				value = "+"
+			}
+		case "", "n":
+			value = w.number(cell.Value, cell.Style)
+		default:
+			value = cell.Value
+		}
+
+		record = append(record, value)
+	}
+
+	return record
+}
+
+// number strips float noise like 100.09999999999999 and turns date serials into dates
+func (w *workbook) number(value string, style int) string {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+
+	if w.dates[style] {
+		return time.Date(1899, 12, 30, 0, 0, 0, 0, time.Local).AddDate(0, 0, int(f)).Format("2006-01-02")
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (w *workbook) readIndex() error {
+	book := new(workbookXml)
+	if err := w.decode("xl/workbook.xml", book); err != nil {
+		return err
+	}
+
+	rels := new(relationshipsXml)
+	if err := w.decode("xl/_rels/workbook.xml.rels", rels); err != nil {
+		return err
+	}
+
+	targets := make(map[string]string)
+	for _, rel := range rels.Relationships {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.Id] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.Id] = path.Join("xl", rel.Target)
+		}
+	}
+
+	for _, sheet := range book.Sheets {
+		w.sheets[sheet.Name] = targets[sheet.Id]
+	}
+
+	sst := new(sharedStringsXml)
+	if err := w.decode("xl/sharedStrings.xml", sst); err == nil {
+		for _, item := range sst.Items {
+			text := item.Text
+			for _, run := range item.Runs {
+				text += run.Text
+			}
+			w.strings = append(w.strings, text)
+		}
+	}
+
+	styles := new(stylesXml)
+	if err := w.decode("xl/styles.xml", styles); err == nil {
+		custom := make(map[int]string)
+		for _, format := range styles.NumberFormats {
+			custom[format.Id] = format.Code
+		}
+
+		for i, xf := range styles.CellFormats {
+			w.dates[i] = isDateFormat(xf.NumberFormatId, custom[xf.NumberFormatId])
+		}
+	}
+
+	return nil
+}
+
+func (w *workbook) decode(name string, v interface{}) error {
+	file, err := w.archive.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return xml.NewDecoder(file).Decode(v)
+}
+
+func isDateFormat(id int, code string) bool {
+	if id >= 14 && id <= 22 {
+		return true
+	}
+
+	var plain strings.Builder
+	skip := rune(0)
+
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case skip != 0:
+			if r == skip {
+				skip = 0
+			}
+		case r == '"':
+			skip = '"'
+		case r == '[':
+			skip = ']'
+		default:
+			plain.WriteRune(r)
+		}
+	}
+
+	return strings.ContainsAny(plain.String(), "yd")
+}
+
+// columnIndex converts a cell reference like "AB12" to a zero based column number
+func columnIndex(ref string) int {
+	column := 0
+
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A') + 1
+	}
+
+	return column - 1
+}
@@ -0,0 +1,146 @@
+package xlsx_schema
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/csv_schema"
+)
+
+// testBook zips the unpacked workbook from testdata/book
+func testBook(t *testing.T) string {
+	t.Helper()
+
+	fileName := filepath.Join(t.TempDir(), "book.xlsx")
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	source := filepath.Join("testdata", "book")
+
+	err = filepath.WalkDir(source, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		relative, _ := filepath.Rel(source, name)
+		w, err := archive.Create(filepath.ToSlash(relative))
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return fileName
+}
+
+func TestReadSheet(t *testing.T) {
+	book, err := openWorkbook(testBook(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer book.Close()
+
+	rows := make([]string, 0)
+	err = book.ReadSheet(csv_schema.TransactionsSheet, func(record []string) error {
+		rows = append(rows, strings.Join(record, "|"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"Executed|Locked|Date|Income account|Income amount|Income balance|Expense account|Expense amount|Expense balance|Comment|Category of Category",
+		`+||2011-02-01||||RUB - Cash|-100.1|899.9|food|\Expenses\Food`,
+		"+||2011-02-02|RUB - Card|5000|5000||||salary|",
+	}
+
+	if len(rows) != len(want) {
+		t.Fatalf("got rows %q, want %q", rows, want)
+	}
+
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %s, want %s", i, rows[i], want[i])
+		}
+	}
+
+	if err = book.ReadSheet("Budget", func([]string) error { return nil }); err == nil {
+		t.Error("a missing sheet is read")
+	}
+}
+
+func TestReadDatabase(t *testing.T) {
+	db, err := ReadDatabase(testBook(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txs := *db.GetTransactions()
+	if len(txs) != 2 || txs[0].Items[0].Account != `Assets\Cash` || txs[0].Items[0].Amount.String() != "-100.10" || txs[1].Note != "salary" {
+		t.Errorf("got transactions %+v", txs)
+	}
+
+	if accounts := *db.GetAccounts(); len(accounts) != 2 || accounts[0].InitBalance.String() != "1000.00" {
+		t.Errorf("got accounts %+v", accounts)
+	}
+
+	if rates := *db.GetRates(); len(rates) != 1 || rates[0].Date.Format("2006-01-02") != "2011-01-01" || rates[0].Amount2.String() != "30.5" {
+		t.Errorf("got rates %+v", rates)
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	for ref, want := range map[string]int{"A1": 0, "B12": 1, "Z3": 25, "AA1": 26, "AB12": 27, "AZ1": 51, "BA1": 52, "XFD1": 16383, "1": -1, "": -1} {
+		if got := columnIndex(ref); got != want {
+			t.Errorf("columnIndex(%q) = %d, want %d", ref, got, want)
+		}
+	}
+}
+
+func TestIsDateFormat(t *testing.T) {
+	tests := []struct {
+		id   int
+		code string
+		want bool
+	}{
+		{0, "", false},
+		{2, "", false},
+		{14, "", true},
+		{22, "", true},
+		{164, `dd\.mm\.yyyy`, true},
+		{164, "yyyy-mm-dd hh:mm", true},
+		{164, "[Red]0.00", false},
+		{164, `0.00" days"`, false},
+		{164, "[$-419]D MMMM YYYY", true},
+	}
+
+	for _, tt := range tests {
+		if got := isDateFormat(tt.id, tt.code); got != tt.want {
+			t.Errorf("isDateFormat(%d, %q) = %v, want %v", tt.id, tt.code, got, tt.want)
+		}
+	}
+}
@@ -2,23 +2,195 @@ package xml_schema
 
 import (
 	"encoding/xml"
-	"io/ioutil"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 
 	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
 )
 
+// ReadDatabase keeps everything but transactions in memory, transactions are streamed by EachTransaction
 func ReadDatabase(fileName string) (schema.Database, error) {
-	data, err := ioutil.ReadFile(fileName)
+	db := &Database{fileName: fileName}
+
+	err := walk(fileName, func(decoder *xml.Decoder, start xml.StartElement) (bool, error) {
+		switch start.Name.Local {
+		case "currencies":
+			return false, decoder.DecodeElement(&struct {
+				Items *[]Currency `xml:"currency"`
+			}{&db.Currencies}, &start)
+		case "rates":
+			return false, eachElement(decoder, func(index int, start xml.StartElement, element *xml.Decoder) error {
+				rate := Rate{}
+
+				if err := element.Decode(&rate); err != nil {
+					db.Add(fileName, location(start, index), err)
+				} else {
+					db.Rates = append(db.Rates, rate)
+				}
+
+				return nil
+			})
+		case "accounts":
+			return false, decoder.DecodeElement(&struct {
+				Items *[]Account `xml:"account"`
+			}{&db.Accounts}, &start)
+		case "account-plans":
+			return false, decoder.DecodeElement(&struct {
+				Items *[]AccountPlan `xml:"account-plan"`
+			}{&db.AccountPlans}, &start)
+		case "classifiers":
+			return false, decoder.DecodeElement(&struct {
+				Items *[]Classifier `xml:"classifier"`
+			}{&db.Classifiers}, &start)
+		default:
+			return false, decoder.Skip()
+		}
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	db := new(Database)
-
-	if err = xml.Unmarshal(data, db); err != nil {
+	if db.AccountsMap, err = db.accountsMap(); err != nil {
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// readTransactions reports transactions with broken values and goes on
+func readTransactions(fileName string, report *schema.Report, callback func(*Transaction) error) error {
+	return walk(fileName, func(decoder *xml.Decoder, start xml.StartElement) (bool, error) {
+		if start.Name.Local != "transactions" {
+			return false, decoder.Skip()
+		}
+
+		return true, eachElement(decoder, func(index int, start xml.StartElement, element *xml.Decoder) error {
+			tx := new(Transaction)
+
+			if err := element.Decode(tx); err != nil {
+				report.Add(fileName, location(start, index), err)
+				return nil
+			}
+
+			return callback(tx)
+		})
+	})
+}
+
+// eachElement decodes children of a section one by one, so a broken value spoils only its element
+func eachElement(decoder *xml.Decoder, callback func(int, xml.StartElement, *xml.Decoder) error) error {
+	for index := 1; ; {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			t = t.Copy()
+
+			element, err := readElement(decoder, t)
+			if err != nil {
+				return err
+			}
+
+			if err = callback(index, t, xml.NewTokenDecoder(element)); err != nil {
+				return err
+			}
+
+			index++
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+type tokens []xml.Token
+
+func (t *tokens) Token() (xml.Token, error) {
+	if len(*t) == 0 {
+		return nil, io.EOF
+	}
+
+	token := (*t)[0]
+	*t = (*t)[1:]
+
+	return token, nil
+}
+
+func readElement(decoder *xml.Decoder, start xml.StartElement) (*tokens, error) {
+	element := tokens{start}
+
+	for depth := 1; depth > 0; {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+
+		element = append(element, xml.CopyToken(token))
+	}
+
+	return &element, nil
+}
+
+// location names an element by its position in the section and its oid
+func location(start xml.StartElement, index int) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "oid" {
+			return fmt.Sprintf("%s #%d (oid %s)", start.Name.Local, index, attr.Value)
+		}
+	}
+
+	return fmt.Sprintf("%s #%d", start.Name.Local, index)
+}
+
+// walk calls handler for every section of the root element until the handler asks to stop
+func walk(fileName string, handler func(*xml.Decoder, xml.StartElement) (bool, error)) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	decoder := xml.NewDecoder(file)
+	root := false
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if !root {
+				if t.Name.Local != "ability-cash" {
+					return errors.New("not an AbilityCash XML file")
+				}
+				root = true
+				continue
+			}
+
+			stop, err := handler(decoder, t)
+			if err != nil {
+				return schema.Locate(fileName, t.Name.Local, err)
+			}
+			if stop {
+				return nil
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
@@ -1,22 +1,21 @@
 package xml_schema
 
 import (
-	"encoding/xml"
-	"log"
+	"fmt"
 
 	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
 	"github.com/Bishop/abilitycash2ledger/ledger"
 )
 
 type Database struct {
-	XMLName      xml.Name      `xml:"ability-cash"`
-	Currencies   []Currency    `xml:"currencies>currency"`
-	Rates        []Rate        `xml:"rates>rate"`
-	Accounts     []Account     `xml:"accounts>account"`
-	AccountPlans []AccountPlan `xml:"account-plans>account-plan"`
-	Transactions []Transaction `xml:"transactions>transaction"`
-	Classifiers  []Classifier  `xml:"classifiers>classifier"`
+	schema.Report
+	Currencies   []Currency
+	Rates        []Rate
+	Accounts     []Account
+	AccountPlans []AccountPlan
+	Classifiers  []Classifier
 	AccountsMap  schema.AccountsMap
+	fileName     string
 }
 
 type Currency struct {
@@ -28,18 +27,18 @@ type Currency struct {
 
 type Rate struct {
 	item
-	Date      acDate  `xml:"date"`
-	Currency1 string  `xml:"currency-1"`
-	Currency2 string  `xml:"currency-2"`
-	Amount1   float64 `xml:"amount-1"`
-	Amount2   float64 `xml:"amount-2"`
+	Date      acDate        `xml:"date"`
+	Currency1 string        `xml:"currency-1"`
+	Currency2 string        `xml:"currency-2"`
+	Amount1   ledger.Amount `xml:"amount-1"`
+	Amount2   ledger.Amount `xml:"amount-2"`
 }
 
 type Account struct {
 	item
-	Name        string  `xml:"name"`
-	Currency    string  `xml:"currency"`
-	InitBalance float64 `xml:"init-balance"`
+	Name        string        `xml:"name"`
+	Currency    string        `xml:"currency"`
+	InitBalance ledger.Amount `xml:"init-balance"`
 }
 
 type AccountPlan struct {
@@ -110,15 +109,15 @@ type txAccount struct {
 }
 
 type txIncome struct {
-	IncomeAccount txAccount `xml:"income-account"`
-	IncomeAmount  float64   `xml:"income-amount"`
-	IncomeBalance float64   `xml:"income-balance"`
+	IncomeAccount txAccount      `xml:"income-account"`
+	IncomeAmount  ledger.Amount  `xml:"income-amount"`
+	IncomeBalance *ledger.Amount `xml:"income-balance"`
 }
 
 type txExpense struct {
-	ExpenseAccount txAccount `xml:"expense-account"`
-	ExpenseAmount  float64   `xml:"expense-amount"`
-	ExpenseBalance float64   `xml:"expense-balance"`
+	ExpenseAccount txAccount      `xml:"expense-account"`
+	ExpenseAmount  ledger.Amount  `xml:"expense-amount"`
+	ExpenseBalance *ledger.Amount `xml:"expense-balance"`
 }
 
 type txCategory struct {
@@ -135,15 +134,13 @@ type txCategoryTI struct {
 }
 
 func (d *Database) GetAccounts() *[]schema.Account {
-	d.cacheAccountsMap()
-
 	accounts := make([]schema.Account, len(d.Accounts))
 
 	for i, account := range d.Accounts {
 		accounts[i] = schema.Account{
 			Name:        d.account(account.Name),
 			Currency:    account.Currency,
-			InitBalance: account.InitBalance,
+			InitBalance: d.amount(account.InitBalance, account.Currency),
 		}
 	}
 
@@ -158,82 +155,126 @@ func (d *Database) GetRates() *[]schema.Rate {
 			Date:      rate.Date.Source(),
 			Currency1: rate.Currency1,
 			Currency2: rate.Currency2,
-			Amount1:   rate.Amount1,
-			Amount2:   rate.Amount2,
+			Amount1:   rate.Amount1.Normalize(),
+			Amount2:   rate.Amount2.Normalize(),
 		}
 	}
 
 	return &rates
 }
 
-func (d *Database) GetTransactions() *[]ledger.Transaction {
-	d.cacheAccountsMap()
-
-	txs := make([]ledger.Transaction, len(d.Transactions))
+func (d *Database) GetCurrencies() *[]schema.Currency {
+	currencies := make([]schema.Currency, len(d.Currencies))
 
-	for i, source := range d.Transactions {
-		if !source.IsExecuted() {
-			continue
+	for i, currency := range d.Currencies {
+		currencies[i] = schema.Currency{
+			Code:      currency.Code,
+			Name:      currency.Name,
+			Precision: currency.Precision,
 		}
+	}
 
-		tx := ledger.Transaction{
-			Date:    source.Date.Source(),
-			Note:    source.Comment,
-			Cleared: source.IsLocked(),
-		}
+	return &currencies
+}
 
-		switch {
-		case source.Transfer != nil:
-			tx.Tags = source.Transfer.Categories.List()
-			tx.Items = []ledger.TxItem{
-				{
-					Account:  d.account(source.Transfer.ExpenseAccount.Name),
-					Currency: source.Transfer.ExpenseAccount.Currency,
-					Amount:   source.Transfer.ExpenseAmount,
-				},
-				{
-					Account:  d.account(source.Transfer.IncomeAccount.Name),
-					Currency: source.Transfer.IncomeAccount.Currency,
-					Amount:   source.Transfer.IncomeAmount,
-				},
-			}
-		case source.Expense != nil:
-			tx.Tags = source.Expense.Categories.List()
-			tx.Items = []ledger.TxItem{
-				{
-					Account:  d.account(source.Expense.ExpenseAccount.Name),
-					Currency: source.Expense.ExpenseAccount.Currency,
-					Amount:   source.Expense.ExpenseAmount,
-				},
-			}
-		case source.Income != nil:
-			tx.Tags = source.Income.Categories.List()
-			tx.Items = []ledger.TxItem{
-				{
-					Account:  d.account(source.Income.IncomeAccount.Name),
-					Currency: source.Income.IncomeAccount.Currency,
-					Amount:   source.Income.IncomeAmount,
-				},
-			}
-		case source.Balance != nil:
-			tx.Items = []ledger.TxItem{
-				{
-					Account:          d.account(source.Balance.IncomeAccount.Name),
-					Currency:         source.Balance.IncomeAccount.Currency,
-					BalanceAssertion: source.Balance.IncomeBalance,
-				},
-				{
-					Account: ledger.Adjustment,
-				},
+func (d *Database) GetClassifiers() []string {
+	names := make([]string, 0)
+
+	for _, classifier := range d.Classifiers {
+		for _, tree := range [][]txCategoryTI{classifier.Income, classifier.Expense, classifier.Single} {
+			for _, category := range tree {
+				names = append(names, category.Name)
 			}
 		}
+	}
+
+	return names
+}
 
-		txs[i] = tx
+// GetTransactions loads the whole list, prefer EachTransaction for large files and to get the reading error
+func (d *Database) GetTransactions() *[]ledger.Transaction {
+	txs := make([]ledger.Transaction, 0)
+
+	err := d.EachTransaction(func(tx ledger.Transaction) error {
+		txs = append(txs, tx)
+		return nil
+	})
+
+	if err != nil {
+		d.Add(d.fileName, "transactions", err)
 	}
 
 	return &txs
 }
 
+// EachTransaction skips transactions with broken values, they are reported by GetProblems
+func (d *Database) EachTransaction(callback func(ledger.Transaction) error) error {
+	return readTransactions(d.fileName, &d.Report, func(source *Transaction) error {
+		if source.Item() == nil || !source.IsExecuted() {
+			return nil
+		}
+
+		return callback(d.transaction(source))
+	})
+}
+
+func (d *Database) transaction(source *Transaction) ledger.Transaction {
+	tx := ledger.Transaction{
+		Id:        source.Oid,
+		ChangedAt: source.ChangedAt.Source(),
+		Date:      source.Date.Source(),
+		Note:      source.Comment,
+		Cleared:   source.IsLocked(),
+	}
+
+	switch {
+	case source.Transfer != nil:
+		tx.Tags = source.Transfer.Categories.List()
+		tx.Items = []ledger.TxItem{
+			d.txItem(source.Transfer.ExpenseAccount, source.Transfer.ExpenseAmount, source.Transfer.ExpenseBalance),
+			d.txItem(source.Transfer.IncomeAccount, source.Transfer.IncomeAmount, source.Transfer.IncomeBalance),
+		}
+	case source.Expense != nil:
+		tx.Tags = source.Expense.Categories.List()
+		tx.Items = []ledger.TxItem{
+			d.txItem(source.Expense.ExpenseAccount, source.Expense.ExpenseAmount, source.Expense.ExpenseBalance),
+		}
+	case source.Income != nil:
+		tx.Tags = source.Income.Categories.List()
+		tx.Items = []ledger.TxItem{
+			d.txItem(source.Income.IncomeAccount, source.Income.IncomeAmount, source.Income.IncomeBalance),
+		}
+	case source.Balance != nil && source.Balance.IncomeBalance != nil:
+		tx.Items = []ledger.TxItem{
+			{
+				Account:             d.account(source.Balance.IncomeAccount.Name),
+				Currency:            source.Balance.IncomeAccount.Currency,
+				BalanceAssertion:    d.amount(*source.Balance.IncomeBalance, source.Balance.IncomeAccount.Currency),
+				HasBalanceAssertion: true,
+			},
+			{
+				Account: ledger.Adjustment,
+			},
+		}
+	}
+
+	return tx
+}
+
+func (d *Database) txItem(account txAccount, amount ledger.Amount, balance *ledger.Amount) ledger.TxItem {
+	item := ledger.TxItem{
+		Account:  d.account(account.Name),
+		Currency: account.Currency,
+		Amount:   d.amount(amount, account.Currency),
+	}
+
+	if balance != nil {
+		item.RunningBalance, item.HasRunningBalance = d.amount(*balance, account.Currency), true
+	}
+
+	return item
+}
+
 func (d *Database) account(a string) string {
 	account, ok := d.AccountsMap[a]
 	if ok {
@@ -243,16 +284,50 @@ func (d *Database) account(a string) string {
 	}
 }
 
-func (d *Database) cacheAccountsMap() {
-	if d.AccountsMap != nil {
-		return
+func (d *Database) amount(a ledger.Amount, currency string) ledger.Amount {
+	for _, c := range d.Currencies {
+		if c.Code != currency {
+			continue
+		}
+
+		// only pads, a value with more digits than the currency or too large to be padded keeps its own precision
+		if c.Precision <= a.Precision() {
+			return a
+		}
+
+		if rescaled, err := a.Rescale(c.Precision); err == nil {
+			return rescaled
+		}
 	}
 
+	return a
+}
+
+func (d *Database) accountsMap() (schema.AccountsMap, error) {
 	if len(d.AccountPlans) != 1 {
-		log.Fatalln("something wrong with accounts plans")
+		return nil, &schema.ReadError{
+			File:     d.fileName,
+			Location: "account-plans",
+			Err:      fmt.Errorf("%d account plans found, expected one", len(d.AccountPlans)),
+		}
 	}
 
-	d.AccountsMap = d.AccountPlans[0].Check(func(duplicate string) {
-		log.Fatalf("duplicate account name: %s", duplicate)
+	var duplicate string
+
+	mapping := d.AccountPlans[0].Check(func(name string) {
+		if duplicate == "" {
+			duplicate = name
+		}
 	})
+
+	if duplicate != "" {
+		return nil, &schema.ReadError{
+			File:     d.fileName,
+			Location: "account-plans",
+			Field:    "name",
+			Err:      fmt.Errorf("duplicate account name %q", duplicate),
+		}
+	}
+
+	return mapping, nil
 }
@@ -0,0 +1,32 @@
+package xml_schema
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+func TestAmountPrecision(t *testing.T) {
+	db := &Database{Currencies: []Currency{{Code: "RUB", Precision: 2}, {Code: "JPY", Precision: 0}}}
+
+	tests := []struct {
+		amount   ledger.Amount
+		currency string
+		want     string
+	}{
+		{ledger.NewAmount(100, 0), "RUB", "100.00"},
+		{ledger.NewAmount(1005, 1), "RUB", "100.50"},
+		{ledger.NewAmount(100125, 3), "RUB", "100.125"},
+		{ledger.NewAmount(-100125, 3), "RUB", "-100.125"},
+		{ledger.NewAmount(1005, 1), "JPY", "100.5"},
+		{ledger.NewAmount(100, 0), "USD", "100"},
+		{ledger.NewAmount(math.MaxInt64, 0), "RUB", "9223372036854775807"},
+	}
+
+	for _, tt := range tests {
+		if got := db.amount(tt.amount, tt.currency); got.String() != tt.want {
+			t.Errorf("amount(%s %s) = %s, want %s", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package xml_schema
 
 import (
 	"encoding/xml"
+	"fmt"
 	"time"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
 )
 
 type acTime struct {
@@ -13,7 +16,7 @@ func (a *acTime) UnmarshalXMLAttr(attr xml.Attr) error {
 	const format = "2006-01-02T15:04:05" // 2011-09-02T20:40:53
 
 	if parse, err := time.ParseInLocation(format, attr.Value, time.Local); err != nil {
-		return err
+		return schema.FieldError(attr.Name.Local, fmt.Errorf("invalid time %q", attr.Value))
 	} else {
 		*a = acTime{parse}
 	}
@@ -39,7 +42,7 @@ func (a *acDate) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	}
 
 	if parse, err := time.ParseInLocation(format, s, time.Local); err != nil {
-		return err
+		return schema.FieldError(start.Name.Local, fmt.Errorf("invalid date %q", s))
 	} else {
 		*a = acDate{parse}
 	}
@@ -0,0 +1,340 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrOverflow is returned when a result does not fit the int64 value of an Amount
+var ErrOverflow = errors.New("amount overflow")
+
+// Amount is a fixed-point decimal: value * 10^-precision
+type Amount struct {
+	value     int64
+	precision uint
+}
+
+func NewAmount(value int64, precision uint) Amount {
+	return Amount{value: value, precision: precision}
+}
+
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+
+	if s == "" {
+		return Amount{}, errors.New("empty amount")
+	}
+
+	negative := false
+
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	integer, fraction, _ := strings.Cut(s, ".")
+
+	if integer == "" {
+		integer = "0"
+	}
+
+	value, err := strconv.ParseInt(integer+fraction, 10, 64)
+
+	if err != nil || strings.ContainsAny(integer+fraction, "+-") {
+		return Amount{}, fmt.Errorf("invalid amount %q", s)
+	}
+
+	if negative {
+		value = -value
+	}
+
+	return Amount{value: value, precision: uint(len(fraction))}, nil
+}
+
+func (a *Amount) UnmarshalText(text []byte) error {
+	parsed, err := ParseAmount(string(text))
+
+	if err != nil {
+		return err
+	}
+
+	*a = parsed
+
+	return nil
+}
+
+// UnmarshalJSON accepts both numbers and strings
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	return a.UnmarshalText([]byte(strings.Trim(string(data), `"`)))
+}
+
+func (a Amount) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a Amount) Precision() uint {
+	return a.precision
+}
+
+func (a Amount) IsZero() bool {
+	return a.value == 0
+}
+
+func (a Amount) Sign() int {
+	switch {
+	case a.value < 0:
+		return -1
+	case a.value > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (a Amount) Neg() Amount {
+	return Amount{value: -a.value, precision: a.precision}
+}
+
+func (a Amount) Abs() Amount {
+	if a.value < 0 {
+		return a.Neg()
+	}
+
+	return a
+}
+
+// Add sums at the larger precision of both amounts
+func (a Amount) Add(b Amount) (Amount, error) {
+	x, y, err := align(a, b)
+	if err != nil {
+		return Amount{}, err
+	}
+
+	value, ok := add64(x.value, y.value)
+	if !ok {
+		return Amount{}, fmt.Errorf("%w: %s + %s", ErrOverflow, a, b)
+	}
+
+	return Amount{value: value, precision: x.precision}, nil
+}
+
+func (a Amount) Sub(b Amount) (Amount, error) {
+	x, y, err := align(a, b)
+	if err != nil {
+		return Amount{}, err
+	}
+
+	value, ok := add64(x.value, -y.value)
+	if !ok || y.value == math.MinInt64 {
+		return Amount{}, fmt.Errorf("%w: %s - %s", ErrOverflow, a, b)
+	}
+
+	return Amount{value: value, precision: x.precision}, nil
+}
+
+// Cmp compares exactly, also amounts whose difference does not fit an Amount
+func (a Amount) Cmp(b Amount) int {
+	x, y, err := align(a, b)
+	if err != nil {
+		// the amount which does not fit the larger precision is the larger one by absolute value
+		if a.precision < b.precision {
+			return a.Sign()
+		}
+		return -b.Sign()
+	}
+
+	switch {
+	case x.value < y.value:
+		return -1
+	case x.value > y.value:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (a Amount) Equal(b Amount) bool {
+	return a.Cmp(b) == 0
+}
+
+// Mul multiplies exactly, the precision of the result is the sum of both precisions
+func (a Amount) Mul(b Amount) (Amount, error) {
+	value, ok := mul64(a.value, b.value)
+	if !ok {
+		return Amount{}, fmt.Errorf("%w: %s * %s", ErrOverflow, a, b)
+	}
+
+	return Amount{value: value, precision: a.precision + b.precision}.Normalize(), nil
+}
+
+// Quo divides a by b and rounds the result to the given precision
+func (a Amount) Quo(b Amount, precision uint) (Amount, error) {
+	if b.value == 0 {
+		return Amount{precision: precision}, nil
+	}
+
+	// a.value * 10^(precision + b.precision + 1) / (b.value * 10^a.precision) gives one extra digit for rounding
+	numerator := new(big.Int).Mul(big.NewInt(a.value), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision+b.precision+1)), nil))
+	denominator := new(big.Int).Mul(big.NewInt(b.value), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(a.precision)), nil))
+
+	quotient := new(big.Int).Quo(numerator, denominator)
+
+	if !quotient.IsInt64() {
+		return Amount{}, fmt.Errorf("%w: %s / %s with %d decimal places", ErrOverflow, a, b, precision)
+	}
+
+	return Amount{value: quotient.Int64(), precision: precision + 1}.Round(precision), nil
+}
+
+// Rescale changes precision, rounding half away from zero when digits are dropped
+func (a Amount) Rescale(precision uint) (Amount, error) {
+	if precision <= a.precision {
+		return a.Round(precision), nil
+	}
+
+	factor, ok := pow10(precision - a.precision)
+	value, fits := mul64(a.value, factor)
+
+	if a.value != 0 && (!ok || !fits) {
+		return Amount{}, fmt.Errorf("%w: %s with %d decimal places", ErrOverflow, a, precision)
+	}
+
+	return Amount{value: value, precision: precision}, nil
+}
+
+// Round drops the digits beyond the precision, rounding half away from zero, a lower precision is kept
+func (a Amount) Round(precision uint) Amount {
+	if precision >= a.precision {
+		return a
+	}
+
+	// any int64 is below the half of 10^20
+	if a.precision-precision > 19 {
+		return Amount{precision: precision}
+	}
+
+	divisor := uint64(1)
+	for n := a.precision - precision; n > 0; n-- {
+		divisor *= 10
+	}
+
+	magnitude := uint64(a.value)
+	if a.value < 0 {
+		magnitude = -magnitude
+	}
+
+	value, remainder := magnitude/divisor, magnitude%divisor
+
+	if remainder >= divisor-remainder {
+		value++
+	}
+
+	if a.value < 0 {
+		return Amount{value: -int64(value), precision: precision}
+	}
+
+	return Amount{value: int64(value), precision: precision}
+}
+
+// Normalize drops trailing zero digits of the fractional part
+func (a Amount) Normalize() Amount {
+	for a.precision > 0 && a.value%10 == 0 {
+		a.value /= 10
+		a.precision--
+	}
+
+	return a
+}
+
+func (a Amount) Float64() float64 {
+	f, _ := strconv.ParseFloat(a.String(), 64)
+
+	return f
+}
+
+func (a Amount) String() string {
+	value := a.value
+	sign := ""
+
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	digits := strconv.FormatInt(value, 10)
+
+	if a.precision == 0 {
+		return sign + digits
+	}
+
+	if pad := int(a.precision) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+
+	point := len(digits) - int(a.precision)
+
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// align brings both amounts to the larger precision
+func align(a, b Amount) (Amount, Amount, error) {
+	if a.precision < b.precision {
+		b, a, err := align(b, a)
+		return a, b, err
+	}
+
+	rescaled, err := b.Rescale(a.precision)
+	if err != nil {
+		return Amount{}, Amount{}, err
+	}
+
+	return a, rescaled, nil
+}
+
+// pow10 is 10^n, false when it does not fit int64
+func pow10(n uint) (int64, bool) {
+	if n > 18 {
+		return 0, false
+	}
+
+	result := int64(1)
+
+	for ; n > 0; n-- {
+		result *= 10
+	}
+
+	return result, true
+}
+
+// add64 adds, false on int64 overflow. MinInt64 is out of range too, it has no opposite value.
+func add64(a, b int64) (int64, bool) {
+	result := a + b
+
+	if a > 0 && b > 0 && result < 0 || a < 0 && b < 0 && result >= 0 || result == math.MinInt64 {
+		return 0, false
+	}
+
+	return result, true
+}
+
+// mul64 multiplies, false on int64 overflow
+func mul64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	result := a * b
+
+	if result/b != a || a == -1 && b == math.MinInt64 || b == -1 && a == math.MinInt64 {
+		return 0, false
+	}
+
+	return result, true
+}
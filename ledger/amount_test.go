@@ -0,0 +1,219 @@
+package ledger
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      string
+		precision uint
+		err       bool
+	}{
+		{"12.50", "12.50", 2, false},
+		{"-0.05", "-0.05", 2, false},
+		{"+7", "7", 0, false},
+		{".5", "0.5", 1, false},
+		{" 1500 ", "1500", 0, false},
+		{"", "", 0, true},
+		{"1.2.3", "", 0, true},
+		{"1-2", "", 0, true},
+		{"abc", "", 0, true},
+		{"99999999999999999999", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseAmount(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil || got.String() != tt.want || got.Precision() != tt.precision {
+			t.Errorf("ParseAmount(%q) = %s (precision %d), %v; want %s (precision %d)", tt.in, got, got.Precision(), err, tt.want, tt.precision)
+		}
+	}
+}
+
+func TestRescale(t *testing.T) {
+	tests := []struct {
+		in        string
+		precision uint
+		want      string
+	}{
+		{"12.5", 2, "12.50"},
+		{"12.345", 2, "12.35"},
+		{"12.344", 2, "12.34"},
+		{"-12.345", 2, "-12.35"},
+		{"-12.344", 2, "-12.34"},
+		{"0.5", 0, "1"},
+		{"-0.5", 0, "-1"},
+		{"0.49", 0, "0"},
+		{"1500", 0, "1500"},
+		{"0.0000000000000000005", 0, "0"},
+	}
+
+	for _, tt := range tests {
+		got, err := mustParse(t, tt.in).Rescale(tt.precision)
+
+		if err != nil || got.String() != tt.want {
+			t.Errorf("%s.Rescale(%d) = %s, %v; want %s", tt.in, tt.precision, got, err, tt.want)
+		}
+	}
+}
+
+func TestRescaleOverflow(t *testing.T) {
+	tests := []struct {
+		amount    Amount
+		precision uint
+	}{
+		{NewAmount(math.MaxInt64/10+1, 0), 1},
+		{NewAmount(-math.MaxInt64/10-1, 0), 1},
+		{NewAmount(1, 0), 19},
+	}
+
+	for _, tt := range tests {
+		if got, err := tt.amount.Rescale(tt.precision); !errors.Is(err, ErrOverflow) {
+			t.Errorf("%s.Rescale(%d) = %s, %v; want an overflow", tt.amount, tt.precision, got, err)
+		}
+	}
+
+	if got, err := NewAmount(0, 0).Rescale(30); err != nil || !got.IsZero() {
+		t.Errorf("0.Rescale(30) = %s, %v; want zero", got, err)
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		amount    Amount
+		precision uint
+		want      string
+	}{
+		{NewAmount(1, 0), 2, "1"},
+		{NewAmount(math.MaxInt64, 2), 0, "92233720368547758"},
+		{NewAmount(math.MinInt64, 1), 0, "-922337203685477581"},
+		{NewAmount(5000000000000000000, 19), 0, "1"},
+		{NewAmount(-4999999999999999999, 19), 0, "0"},
+		{NewAmount(math.MaxInt64, 25), 0, "0"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.Round(tt.precision); got.String() != tt.want {
+			t.Errorf("%s.Round(%d) = %s, want %s", tt.amount, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestQuo(t *testing.T) {
+	tests := []struct {
+		a, b      string
+		precision uint
+		want      string
+	}{
+		{"0.7", "100", 8, "0.00700000"},
+		{"10", "3", 2, "3.33"},
+		{"20", "3", 2, "6.67"},
+		{"-20", "3", 2, "-6.67"},
+		{"1", "-8", 2, "-0.13"},
+		{"2500", "10", 0, "250"},
+		{"1", "0", 2, "0.00"},
+	}
+
+	for _, tt := range tests {
+		got, err := mustParse(t, tt.a).Quo(mustParse(t, tt.b), tt.precision)
+
+		if err != nil || got.String() != tt.want {
+			t.Errorf("%s.Quo(%s, %d) = %s, %v; want %s", tt.a, tt.b, tt.precision, got, err, tt.want)
+		}
+	}
+
+	if got, err := NewAmount(math.MaxInt64, 0).Quo(NewAmount(1, 2), 8); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Quo = %s, %v; want an overflow", got, err)
+	}
+}
+
+func TestMul(t *testing.T) {
+	if got, err := NewAmount(125, 2).Mul(NewAmount(4, 1)); err != nil || got.String() != "0.5" {
+		t.Errorf("1.25 * 0.4 = %s, %v; want 0.5", got, err)
+	}
+
+	if got, err := NewAmount(1<<62, 0).Mul(NewAmount(4, 0)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("2^62 * 4 = %s, %v; want an overflow", got, err)
+	}
+}
+
+func TestAddAlign(t *testing.T) {
+	tests := []struct {
+		a, b Amount
+		want string
+	}{
+		{NewAmount(125, 1), NewAmount(5, 2), "12.55"},
+		{NewAmount(-1, 0), NewAmount(5, 2), "-0.95"},
+		{NewAmount(math.MaxInt64-1, 0), NewAmount(1, 0), "9223372036854775807"},
+		{NewAmount(math.MinInt64+2, 0), NewAmount(-1, 0), "-9223372036854775807"},
+		// the common precision does not fit
+		{NewAmount(math.MaxInt64/2, 0), NewAmount(5, 1), ""},
+		{NewAmount(math.MaxInt64, 0), NewAmount(1, 0), ""},
+		{NewAmount(math.MinInt64+1, 0), NewAmount(-1, 0), ""},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.a.Add(tt.b)
+
+		if tt.want == "" {
+			if !errors.Is(err, ErrOverflow) {
+				t.Errorf("%s + %s = %s, %v; want an overflow", tt.a, tt.b, got, err)
+			}
+			continue
+		}
+
+		if err != nil || got.String() != tt.want {
+			t.Errorf("%s + %s = %s, %v; want %s", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+}
+
+func TestSubCmpOverflow(t *testing.T) {
+	if got, err := NewAmount(math.MinInt64+1, 0).Sub(NewAmount(2, 0)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("MinInt64+1 - 2 = %s, %v; want an overflow", got, err)
+	}
+
+	if got, err := NewAmount(0, 0).Sub(NewAmount(math.MinInt64, 0)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("0 - MinInt64 = %s, %v; want an overflow", got, err)
+	}
+
+	tests := []struct {
+		a, b Amount
+		want int
+	}{
+		{NewAmount(math.MaxInt64, 0), NewAmount(-1, 0), 1},
+		{NewAmount(math.MinInt64, 0), NewAmount(1, 0), -1},
+		{NewAmount(math.MaxInt64/2, 0), NewAmount(5, 1), 1},
+		{NewAmount(5, 1), NewAmount(math.MaxInt64/2, 0), -1},
+		{NewAmount(5, 1), NewAmount(-math.MaxInt64/2, 0), 1},
+		{NewAmount(50, 2), NewAmount(5, 1), 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Cmp(tt.b); got != tt.want {
+			t.Errorf("cmp(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func mustParse(t *testing.T, s string) Amount {
+	t.Helper()
+
+	amount, err := ParseAmount(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return amount
+}
@@ -0,0 +1,536 @@
+package ledger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var dateFormats = []string{"2006-01-02", "2006/01/02", "2006.01.02"}
+
+// Position points to a line of a journal file
+type Position struct {
+	File string
+	Line int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+type JournalError struct {
+	Position
+	Message string
+}
+
+func (e *JournalError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Message)
+}
+
+// Journal is a small subset of the ledger format, enough to check the files this tool writes
+type Journal struct {
+	Accounts     map[string]Position
+	Commodities  map[string]Position
+	Prices       []Price
+	Transactions []JournalTransaction
+	Errors       []error
+}
+
+type Price struct {
+	Position
+	Date      time.Time
+	Commodity string
+	Amount    Amount
+	Quote     string
+}
+
+type JournalTransaction struct {
+	Position
+	Date  time.Time
+	Payee string
+	// Period is the expression of a periodic transaction, it has no date
+	Period   string
+	Postings []Posting
+}
+
+type Posting struct {
+	Position
+	Account   string
+	Virtual   bool
+	HasAmount bool
+	Amount    Amount
+	Commodity string
+	// Cost is the total cost of the posting in CostCommodity, given with @ or @@
+	HasCost       bool
+	Cost          Amount
+	CostCommodity string
+	HasAssertion  bool
+	Assertion     Amount
+}
+
+func NewJournal() *Journal {
+	return &Journal{
+		Accounts:    make(map[string]Position),
+		Commodities: make(map[string]Position),
+	}
+}
+
+func (j *Journal) ReadFile(fileName string) error {
+	file, err := os.Open(fileName)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return j.Read(file, fileName)
+}
+
+// Read parses the journal, syntax problems are collected in Errors, only reading errors are returned
+func (j *Journal) Read(r io.Reader, fileName string) error {
+	scanner := bufio.NewScanner(r)
+	position := Position{File: fileName}
+
+	var tx *JournalTransaction
+	directive := false
+
+	for scanner.Scan() {
+		position.Line++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		switch {
+		case line == "":
+			tx, directive = j.flush(tx), false
+		case line[0] == ' ' || line[0] == '\t':
+			trimmed := strings.TrimSpace(line)
+
+			switch {
+			case strings.HasPrefix(trimmed, ";"), directive:
+			case tx != nil:
+				tx.Postings = append(tx.Postings, j.posting(trimmed, position))
+			default:
+				j.errorf(position, "unexpected indented line")
+			}
+		case strings.ContainsRune(";#*%|", rune(line[0])):
+			tx, directive = j.flush(tx), false
+		case line[0] >= '0' && line[0] <= '9':
+			j.flush(tx)
+			tx, directive = j.transaction(line, position), false
+		case line[0] == '~':
+			j.flush(tx)
+			tx, directive = j.periodic(line, position), false
+		default:
+			tx, directive = j.flush(tx), true
+
+			if err := j.directive(line, position); err != nil {
+				return err
+			}
+		}
+	}
+
+	j.flush(tx)
+
+	return scanner.Err()
+}
+
+func (j *Journal) flush(tx *JournalTransaction) *JournalTransaction {
+	if tx != nil {
+		j.Transactions = append(j.Transactions, *tx)
+	}
+
+	return nil
+}
+
+func (j *Journal) directive(line string, position Position) error {
+	name, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(stripComment(rest))
+
+	switch name {
+	case "account":
+		j.Accounts[rest] = position
+	case "commodity":
+		if _, commodity, err := parseCommodityAmount(rest); err == nil && commodity != "" {
+			rest = commodity
+		}
+		j.Commodities[unquote(rest)] = position
+	case "P":
+		j.price(rest, position)
+	case "include":
+		return j.ReadFile(filepath.Join(filepath.Dir(position.File), rest))
+	default:
+		j.errorf(position, "unknown directive %q", name)
+	}
+
+	return nil
+}
+
+// DeclarationKey names what a directive declares: "account NAME", "commodity SYMBOL" or "P DATE COMMODITY QUOTE",
+// so repeated declarations can be found whatever their format, comments and subdirectives. Other lines are their own key.
+func DeclarationKey(line string) string {
+	name, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	rest = strings.TrimSpace(stripComment(rest))
+
+	switch name {
+	case "account":
+		return name + " " + rest
+	case "commodity":
+		if _, commodity, err := parseCommodityAmount(rest); err == nil && commodity != "" {
+			rest = commodity
+		}
+		return name + " " + unquote(rest)
+	case "P":
+		date, rest, _ := strings.Cut(rest, " ")
+		commodity, price := cutCommodity(rest)
+		if _, quote, err := parseCommodityAmount(price); err == nil {
+			return strings.Join([]string{name, date, unquote(commodity), quote}, " ")
+		}
+	}
+
+	return line
+}
+
+func (j *Journal) price(s string, position Position) {
+	field, rest, _ := strings.Cut(strings.TrimSpace(s), " ")
+
+	// the time after the date is optional
+	if next, after, _ := strings.Cut(strings.TrimSpace(rest), " "); strings.Contains(next, ":") {
+		rest = after
+	}
+
+	date, err := parseDate(field)
+	if err != nil {
+		j.errorf(position, "%v", err)
+		return
+	}
+
+	commodity, price := cutCommodity(rest)
+	if commodity == "" || price == "" || isNumeric(commodity[0]) || commodity[0] == '-' {
+		j.errorf(position, "invalid price %q", s)
+		return
+	}
+
+	amount, quote, err := parseCommodityAmount(price)
+	if err != nil || quote == "" {
+		j.errorf(position, "invalid price %q", s)
+		return
+	}
+
+	j.Prices = append(j.Prices, Price{
+		Position:  position,
+		Date:      date,
+		Commodity: unquote(commodity),
+		Amount:    amount,
+		Quote:     quote,
+	})
+}
+
+func (j *Journal) transaction(line string, position Position) *JournalTransaction {
+	tx := &JournalTransaction{Position: position}
+
+	field, rest, _ := strings.Cut(stripComment(line), " ")
+	field, _, _ = strings.Cut(field, "=")
+
+	date, err := parseDate(field)
+	if err != nil {
+		j.errorf(position, "%v", err)
+	}
+
+	rest = strings.TrimSpace(rest)
+	rest = strings.TrimSpace(strings.TrimLeft(rest, "*!"))
+
+	if strings.HasPrefix(rest, "(") {
+		if end := strings.Index(rest, ")"); end > 0 {
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+	}
+
+	tx.Date, tx.Payee = date, rest
+
+	return tx
+}
+
+// periodic reads "~ monthly from 2022-01-01  payee", the description after two spaces is optional
+func (j *Journal) periodic(line string, position Position) *JournalTransaction {
+	period, payee, _ := strings.Cut(strings.TrimSpace(stripComment(line[1:])), "  ")
+
+	if period == "" {
+		j.errorf(position, "periodic transaction without a period")
+	}
+
+	return &JournalTransaction{Position: position, Period: period, Payee: strings.TrimSpace(payee)}
+}
+
+func (j *Journal) posting(line string, position Position) Posting {
+	p := Posting{Position: position}
+
+	line = strings.TrimSpace(strings.TrimLeft(stripComment(line), "*!"))
+
+	// the account name ends with a tab or two spaces
+	end := len(line)
+	if i := strings.Index(line, "  "); i >= 0 {
+		end = i
+	}
+	if i := strings.IndexByte(line, '\t'); i >= 0 && i < end {
+		end = i
+	}
+
+	account, rest := line[:end], line[end:]
+
+	if n := len(account); n > 1 && (account[0] == '(' && account[n-1] == ')' || account[0] == '[' && account[n-1] == ']') {
+		p.Virtual = account[0] == '('
+		account = account[1 : n-1]
+	}
+
+	p.Account = account
+
+	value, assertion, hasAssertion := strings.Cut(rest, "=")
+
+	if hasAssertion {
+		amount, _, err := parseCommodityAmount(assertion)
+		if err != nil {
+			j.errorf(position, "invalid balance assertion %q", strings.TrimSpace(assertion))
+		}
+		p.HasAssertion, p.Assertion = true, amount
+	}
+
+	value, cost, hasCost := strings.Cut(value, "@")
+	value, lot := cutLot(value)
+
+	if value = strings.TrimSpace(value); value == "" {
+		return p
+	}
+
+	amount, commodity, err := parseCommodityAmount(value)
+	if err != nil {
+		j.errorf(position, "invalid amount %q", value)
+		return p
+	}
+
+	p.HasAmount, p.Amount, p.Commodity = true, amount, commodity
+
+	// the price given with @ balances the posting, the lot price does it without one
+	switch {
+	case hasCost:
+		total := strings.HasPrefix(cost, "@")
+		p.HasCost, p.Cost, p.CostCommodity, err = postingCost(strings.TrimPrefix(cost, "@"), total, amount)
+	case lot != "":
+		total := strings.HasPrefix(lot, "{{")
+		p.HasCost, p.Cost, p.CostCommodity, err = postingCost(strings.TrimLeft(strings.Trim(lot, "{}"), "="), total, amount)
+	}
+
+	if err != nil {
+		j.errorf(position, "invalid cost %q", strings.TrimSpace(cost+lot))
+	}
+
+	return p
+}
+
+// cutLot takes the lot price annotation, "{250 USD}" or "{{2500 USD}}", out of the amount
+func cutLot(value string) (string, string) {
+	start, end := strings.IndexByte(value, '{'), strings.LastIndexByte(value, '}')
+
+	if start < 0 || end < start {
+		return value, ""
+	}
+
+	return value[:start] + value[end+1:], value[start : end+1]
+}
+
+// postingCost is the total cost of the amount, the price is given per unit or for the whole amount
+func postingCost(s string, total bool, amount Amount) (bool, Amount, string, error) {
+	price, commodity, err := parseCommodityAmount(s)
+	if err != nil {
+		return false, Amount{}, "", err
+	}
+
+	if total {
+		price = price.Abs()
+		if amount.Sign() < 0 {
+			price = price.Neg()
+		}
+	} else if price, err = price.Mul(amount); err != nil {
+		return false, Amount{}, "", err
+	}
+
+	return true, price, commodity, nil
+}
+
+// Verify checks that transactions balance and use declared accounts, problems are added to Errors
+func (j *Journal) Verify() {
+	for _, tx := range j.Transactions {
+		j.verifyTransaction(tx)
+
+		for _, p := range tx.Postings {
+			if _, ok := j.Accounts[p.Account]; !ok {
+				j.errorf(p.Position, "account %q is not declared", p.Account)
+			}
+		}
+	}
+}
+
+func (j *Journal) verifyTransaction(tx JournalTransaction) {
+	sums := make(map[string]Amount)
+	precisions := make(map[string]uint)
+	elided, assigned, plain := 0, 0, 0
+	var err error
+
+	for _, p := range tx.Postings {
+		if precision, ok := precisions[p.Commodity]; p.HasAmount && (!ok || p.Amount.Precision() > precision) {
+			precisions[p.Commodity] = p.Amount.Precision()
+		}
+
+		switch {
+		case p.Virtual:
+		case !p.HasAmount && p.HasAssertion:
+			assigned++
+		case !p.HasAmount:
+			elided++
+		case p.HasCost:
+			sums[p.CostCommodity], err = sums[p.CostCommodity].Add(p.Cost)
+		default:
+			sums[p.Commodity], err = sums[p.Commodity].Add(p.Amount)
+			if !p.Amount.IsZero() {
+				plain++
+			}
+		}
+
+		if err != nil {
+			j.errorf(tx.Position, "%v", err)
+			return
+		}
+	}
+
+	if elided > 1 {
+		j.errorf(tx.Position, "more than one posting without amount")
+		return
+	}
+
+	if len(tx.Postings) == 0 {
+		j.errorf(tx.Position, "transaction has no postings")
+		return
+	}
+
+	if elided+assigned > 0 {
+		return
+	}
+
+	rest := make([]string, 0)
+	signs := 0
+
+	// like ledger, a difference below the precision of the amounts is not counted, unit prices are rounded
+	for commodity, sum := range sums {
+		if precision, ok := precisions[commodity]; ok {
+			sum = sum.Round(precision)
+		}
+
+		if !sum.IsZero() {
+			rest = append(rest, strings.TrimSpace(fmt.Sprintf("%s %s", sum, commodity)))
+			signs += sum.Sign()
+		}
+	}
+
+	sort.Strings(rest)
+
+	// two postings of different commodities left without explicit costs are an exchange, ledger infers the rate.
+	// With more postings a typo or a wrong sign would pass as a rate.
+	if len(rest) == 0 || len(rest) == 2 && signs == 0 && plain == 2 {
+		return
+	}
+
+	j.errorf(tx.Position, "transaction does not balance: %s", strings.Join(rest, ", "))
+}
+
+func (j *Journal) errorf(position Position, format string, args ...interface{}) {
+	j.Errors = append(j.Errors, &JournalError{Position: position, Message: fmt.Sprintf(format, args...)})
+}
+
+// parseCommodityAmount reads "10.00 USD", "USD 10.00", "$10" or "-$10"
+func parseCommodityAmount(s string) (Amount, string, error) {
+	s = strings.TrimSpace(s)
+	sign := ""
+
+	if strings.HasPrefix(s, "-") && len(s) > 1 && !isNumeric(s[1]) {
+		sign, s = "-", s[1:]
+	}
+
+	var number, commodity string
+
+	switch {
+	case s == "":
+		return Amount{}, "", fmt.Errorf("empty amount")
+	case isNumeric(s[0]) || s[0] == '-' || s[0] == '+':
+		end := strings.IndexFunc(s[1:], func(r rune) bool { return !isNumeric(byte(r)) || r > 127 }) + 1
+		if end == 0 {
+			end = len(s)
+		}
+		number, commodity = s[:end], strings.TrimSpace(s[end:])
+	case s[0] == '"':
+		end := strings.Index(s[1:], `"`) + 2
+		if end < 2 {
+			return Amount{}, "", fmt.Errorf("unterminated commodity %q", s)
+		}
+		commodity, number = s[:end], strings.TrimSpace(s[end:])
+	default:
+		end := strings.IndexAny(s, "-+0123456789. ")
+		if end < 0 {
+			return Amount{}, "", fmt.Errorf("no number in %q", s)
+		}
+		commodity, number = s[:end], strings.TrimSpace(s[end:])
+	}
+
+	amount, err := ParseAmount(sign + number)
+
+	return amount, unquote(commodity), err
+}
+
+// cutCommodity takes the first word off the string, a quoted commodity keeps its quotes and spaces
+func cutCommodity(s string) (string, string) {
+	s = strings.TrimSpace(s)
+
+	if strings.HasPrefix(s, `"`) {
+		if end := strings.Index(s[1:], `"`); end >= 0 {
+			return s[:end+2], strings.TrimSpace(s[end+2:])
+		}
+	}
+
+	commodity, rest, _ := strings.Cut(s, " ")
+
+	return commodity, strings.TrimSpace(rest)
+}
+
+func isNumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c == '.'
+}
+
+func unquote(s string) string {
+	if len(s) > 1 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
+
+// stripComment cuts a trailing comment, which starts with ";" in the ledger format
+func stripComment(s string) string {
+	if i := strings.Index(s, ";"); i >= 0 {
+		return strings.TrimRight(s[:i], " \t")
+	}
+
+	return s
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, format := range dateFormats {
+		if date, err := time.ParseInLocation(format, s, time.Local); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
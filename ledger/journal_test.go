@@ -0,0 +1,178 @@
+package ledger
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseCommodityAmount(t *testing.T) {
+	tests := []struct {
+		in        string
+		amount    string
+		commodity string
+		err       bool
+	}{
+		{"10.00 USD", "10.00", "USD", false},
+		{"-10.00 USD", "-10.00", "USD", false},
+		{"USD 10.00", "10.00", "USD", false},
+		{"USD -10.00", "-10.00", "USD", false},
+		{"$10", "10", "$", false},
+		{"-$10.50", "-10.50", "$", false},
+		{"$ 10", "10", "$", false},
+		{"1500 ₽", "1500", "₽", false},
+		{"1500₽", "1500", "₽", false},
+		{"-1500.5 ₽", "-1500.5", "₽", false},
+		{"€-5", "-5", "€", false},
+		{`"RUB1" 20`, "20", "RUB1", false},
+		{`20 "US Dollar"`, "20", "US Dollar", false},
+		{"42", "42", "", false},
+		{"", "", "", true},
+		{"USD", "", "", true},
+		{`"RUB 20`, "", "", true},
+	}
+
+	for _, tt := range tests {
+		amount, commodity, err := parseCommodityAmount(tt.in)
+
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseCommodityAmount(%q) = %s %q, want an error", tt.in, amount, commodity)
+			}
+			continue
+		}
+
+		if err != nil || amount.String() != tt.amount || commodity != tt.commodity {
+			t.Errorf("parseCommodityAmount(%q) = %s %q, %v; want %s %q", tt.in, amount, commodity, err, tt.amount, tt.commodity)
+		}
+	}
+}
+
+func TestPostingCost(t *testing.T) {
+	tests := []struct {
+		cost   string
+		total  bool
+		amount Amount
+		want   string
+	}{
+		{"250 USD", false, NewAmount(10, 0), "2500"},
+		{"250 USD", false, NewAmount(-10, 0), "-2500"},
+		{"2500 USD", true, NewAmount(-10, 0), "-2500"},
+		{"-2500 USD", true, NewAmount(10, 0), "2500"},
+		{"0.0325 USD", false, NewAmount(1000, 2), "0.325"},
+	}
+
+	for _, tt := range tests {
+		ok, cost, commodity, err := postingCost(tt.cost, tt.total, tt.amount)
+
+		if !ok || err != nil || cost.String() != tt.want || commodity != "USD" {
+			t.Errorf("postingCost(%q, %v, %s) = %s %s, %v; want %s USD", tt.cost, tt.total, tt.amount, cost, commodity, err, tt.want)
+		}
+	}
+
+	if _, _, _, err := postingCost("9223372036854775807 USD", false, NewAmount(10, 0)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("postingCost of a huge price = %v, want an overflow", err)
+	}
+}
+
+func TestReadPrices(t *testing.T) {
+	journal := NewJournal()
+	source := `P 2011-01-01 JPY 0.007 USD
+P 2011/01/02 00:00:00 USD 30.5 RUB
+P 2011-01-03 $ 31 ₽
+P 2011-01-04 "RUB1" RUB 2
+P 2011-01-04 "JPY 2" 0.007 USD
+P 2011-01-05 USD
+P 2011-01-06 10 USD
+`
+
+	if err := journal.Read(strings.NewReader(source), "rates.journal"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"JPY 0.007 USD", "USD 30.5 RUB", "$ 31 ₽", "RUB1 2 RUB", "JPY 2 0.007 USD"}
+
+	if len(journal.Prices) != len(want) {
+		t.Fatalf("got %d prices, want %d", len(journal.Prices), len(want))
+	}
+
+	for i, price := range journal.Prices {
+		if got := price.Commodity + " " + price.Amount.String() + " " + price.Quote; got != want[i] {
+			t.Errorf("price %d = %s, want %s", i, got, want[i])
+		}
+	}
+
+	if len(journal.Errors) != 2 {
+		t.Errorf("got errors %v, want two invalid prices", journal.Errors)
+	}
+}
+
+func TestVerifyBalance(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   string
+		err  string
+	}{
+		{"balanced", "    Cash  -10.00 USD\n    Food  10.00 USD\n", ""},
+		{"elided", "    Cash\n    Food  10.00 USD\n", ""},
+		{"two elided", "    Cash\n    Card\n    Food  10.00 USD\n", "more than one posting without amount"},
+		{"unbalanced", "    Cash  -10.00 USD\n    Food  9.00 USD\n", "transaction does not balance: -1.00 USD"},
+		{"exchange", "    Card  -3000 RUB\n    Wallet  100 USD\n", ""},
+		{"exchange with a fee", "    Card  -3000 RUB\n    Wallet  100 USD\n    Food  10 RUB\n", "transaction does not balance: -2990 RUB, 100 USD"},
+		{"exchange with a wrong sign", "    Card  -3000 RUB\n    Wallet  100 USD\n    Cash  -1000 RUB\n", "transaction does not balance: -4000 RUB, 100 USD"},
+		{"exchange with a typo", "    Card  -3000 RUB\n    Wallet  100 USD\n    Food  -10 USD\n    Cash  1 USD\n", "transaction does not balance: -3000 RUB, 91 USD"},
+		{"unit price", "    Broker  -2500 USD\n    Shares  10 MSFT @ 250 USD\n", ""},
+		{"wrong total price", "    Broker  -2000 USD\n    Shares  10 MSFT @@ 2500 USD\n", "transaction does not balance: 500 USD"},
+		{"assertion", "    Cash  -10.00 USD = 0 USD\n    Food  10.00 USD\n", ""},
+		{"assignment", "    Cash  = 90.00 USD\n    Equity:Adjustments\n", ""},
+		{"overflow", "    Cash  9223372036854775807 USD\n    Food  1 USD\n", "amount overflow: 9223372036854775807 + 1"},
+		{"bad assertion", "    Cash  -10.00 USD = zero\n    Food  10.00 USD\n", `invalid balance assertion "zero"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journal := NewJournal()
+
+			for _, account := range []string{"Cash", "Card", "Food", "Wallet", "Broker", "Shares", "Equity:Adjustments"} {
+				journal.Accounts[account] = Position{}
+			}
+
+			if err := journal.Read(strings.NewReader("2011-01-01 Shop\n"+tt.tx), "txs.journal"); err != nil {
+				t.Fatal(err)
+			}
+
+			journal.Verify()
+
+			switch {
+			case tt.err == "" && len(journal.Errors) > 0:
+				t.Errorf("got errors %v, want none", journal.Errors)
+			case tt.err != "" && (len(journal.Errors) != 1 || !strings.HasSuffix(journal.Errors[0].Error(), tt.err)):
+				t.Errorf("got errors %v, want %q", journal.Errors, tt.err)
+			}
+		})
+	}
+}
+
+func TestDeclarationKey(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"account Assets:Cash", "account Assets:Cash"},
+		{"account Assets:Cash  ; type: A", "account Assets:Cash"},
+		{"commodity ₽", "commodity ₽"},
+		{"commodity 1000.00 ₽", "commodity ₽"},
+		{"commodity $1000.00", "commodity $"},
+		{`commodity "JPY 2"`, "commodity JPY 2"},
+		{`commodity "JPY 2" 1000`, "commodity JPY 2"},
+		{"P 2011-02-04 $ 30.5 ₽  ; exchange", "P 2011-02-04 $ ₽"},
+		{`P 2011-02-04 "JPY 2" 0.007 USD`, "P 2011-02-04 JPY 2 USD"},
+		{"include main-accounts.journal", "include main-accounts.journal"},
+	}
+
+	for _, tt := range tests {
+		if got := DeclarationKey(tt.line); got != tt.want {
+			t.Errorf("DeclarationKey(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package ledger
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	Daily   = "day"
+	Weekly  = "week"
+	Monthly = "month"
+	Yearly  = "year"
+)
+
+var periodNames = map[string]string{
+	Daily:   "daily",
+	Weekly:  "weekly",
+	Monthly: "monthly",
+	Yearly:  "yearly",
+}
+
+// Period is the recurrence of a periodic transaction, Start is the first occurrence
+type Period struct {
+	Unit     string
+	Interval int
+	Start    time.Time
+}
+
+// String is a period expression understood by hledger: "monthly from 2022-01-01". hledger wants the start
+// on a period boundary, so a monthly or weekly schedule starting on another day names the day instead:
+// "every 15th day of month from 2022-01-15". A schedule of several periods can not name it, its start moves
+// to the boundary, budget reports compare whole periods anyway.
+func (p Period) String() string {
+	if p.Interval <= 1 {
+		weekday := (int(p.Start.Weekday())+6)%7 + 1
+
+		switch {
+		case p.Unit == Monthly && p.Start.Day() != 1:
+			return fmt.Sprintf("every %s day of month from %s", ordinal(p.Start.Day()), p.Start.Format("2006-01-02"))
+		case p.Unit == Weekly && weekday != 1:
+			return fmt.Sprintf("every %s day of week from %s", ordinal(weekday), p.Start.Format("2006-01-02"))
+		}
+	}
+
+	every := periodNames[p.Unit]
+
+	if p.Interval > 1 {
+		every = fmt.Sprintf("every %d %ss", p.Interval, p.Unit)
+	}
+
+	return fmt.Sprintf("%s from %s", every, p.boundary().Format("2006-01-02"))
+}
+
+// Ledger is the period expression for ledger, its grammar has no day of period, so the schedule starts
+// on the first occurrence: "every 3 months from 2022-01-15"
+func (p Period) Ledger() string {
+	every := periodNames[p.Unit]
+
+	if p.Interval > 1 {
+		every = fmt.Sprintf("every %d %ss", p.Interval, p.Unit)
+	}
+
+	return fmt.Sprintf("%s from %s", every, p.Start.Format("2006-01-02"))
+}
+
+func (p Period) boundary() time.Time {
+	year, month, day := p.Start.Date()
+
+	switch p.Unit {
+	case Weekly:
+		// weeks start on Monday
+		return time.Date(year, month, day-(int(p.Start.Weekday())+6)%7, 0, 0, 0, 0, p.Start.Location())
+	case Monthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, p.Start.Location())
+	case Yearly:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, p.Start.Location())
+	default:
+		return p.Start
+	}
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+
+	return fmt.Sprintf("%d%s", n, suffix)
+}
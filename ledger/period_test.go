@@ -0,0 +1,67 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodString(t *testing.T) {
+	day := func(s string) time.Time {
+		date, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return date
+	}
+
+	tests := []struct {
+		period Period
+		want   string
+	}{
+		{Period{Monthly, 1, day("2022-01-01")}, "monthly from 2022-01-01"},
+		{Period{Monthly, 1, day("2022-01-15")}, "every 15th day of month from 2022-01-15"},
+		{Period{Monthly, 1, day("2022-01-22")}, "every 22nd day of month from 2022-01-22"},
+		{Period{Monthly, 1, day("2022-01-31")}, "every 31st day of month from 2022-01-31"},
+		{Period{Monthly, 3, day("2022-01-15")}, "every 3 months from 2022-01-01"},
+		{Period{Weekly, 1, day("2022-01-03")}, "weekly from 2022-01-03"},
+		{Period{Weekly, 1, day("2022-01-05")}, "every 3rd day of week from 2022-01-05"},
+		{Period{Weekly, 2, day("2022-01-05")}, "every 2 weeks from 2022-01-03"},
+		{Period{Yearly, 1, day("2022-03-15")}, "yearly from 2022-01-01"},
+		{Period{Daily, 1, day("2022-03-15")}, "daily from 2022-03-15"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.period.String(); got != tt.want {
+			t.Errorf("%+v = %q, want %q", tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestPeriodLedger(t *testing.T) {
+	start := time.Date(2022, 1, 15, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		period Period
+		want   string
+	}{
+		{Period{Monthly, 1, start}, "monthly from 2022-01-15"},
+		{Period{Monthly, 3, start}, "every 3 months from 2022-01-15"},
+		{Period{Weekly, 1, start}, "weekly from 2022-01-15"},
+		{Period{Weekly, 2, start}, "every 2 weeks from 2022-01-15"},
+		{Period{Yearly, 1, start}, "yearly from 2022-01-15"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.period.Ledger(); got != tt.want {
+			t.Errorf("%+v = %q, want %q", tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, want := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 23: "23rd", 31: "31st"} {
+		if got := ordinal(n); got != want {
+			t.Errorf("ordinal(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
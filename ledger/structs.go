@@ -5,9 +5,19 @@ import "time"
 const (
 	OpeningBalance = "Equity:Opening balances"
 	Adjustment     = "Equity:Adjustments"
+	Unknown        = "Equity:Unknown"
+)
+
+// Cost modes of a posting: a unit price (@), a total price (@@) or a lot price ({})
+const (
+	UnitCost  = "unit"
+	TotalCost = "total"
+	LotCost   = "lot"
 )
 
 type Transaction struct {
+	Id            string
+	ChangedAt     time.Time
 	Date          time.Time
 	Payee         string
 	Note          string
@@ -19,12 +29,15 @@ type Transaction struct {
 	Metadata      map[string]string
 	TypedMetadata map[string]interface{}
 	Tags          []string
+	// Period is set for scheduled transactions, they are written as periodic ones
+	Period *Period
 }
 
 type TxItem struct {
+	Id       string
 	Account  string
 	Currency string
-	Amount   float64
+	Amount   Amount
 	Note     string
 	Cleared  bool
 	Pending  bool
@@ -33,5 +46,19 @@ type TxItem struct {
 	Virtual  bool
 	Balanced bool
 
-	BalanceAssertion float64
+	// Cost is in CostCurrency: per unit for the unit and lot modes, for the whole amount in the total mode
+	Cost         Amount
+	CostCurrency string
+	CostMode     string
+
+	// BalanceAssertion is the account balance after the posting, a posting without an amount assigns it
+	BalanceAssertion    Amount
+	HasBalanceAssertion bool
+
+	// RunningBalance is the account balance after the transaction as the source reports it
+	RunningBalance    Amount
+	HasRunningBalance bool
+
+	Tags     []string
+	Metadata map[string]string
 }
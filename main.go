@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -17,7 +18,9 @@ const scopeFile = "./scope.json"
 var config = scope.NewScope()
 
 func main() {
-	readScope()
+	if err := readScope(); err != nil {
+		log.Fatal(err)
+	}
 
 	app := cli.App{
 		Name:    "abilitycash2ledger",
@@ -41,6 +44,28 @@ func main() {
 				Aliases: []string{"c"},
 				Usage:   "Convert added datafiles to ledger format",
 				Action:  convert,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "incremental",
+						Usage: "append only new and changed transactions to a dated journal",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "print a summary and a diff against the current journals instead of writing them",
+					},
+				},
+			},
+			{
+				Name:    "verify",
+				Aliases: []string{"v"},
+				Usage:   "Check that converted journals are balanced and declare every account",
+				Action:  verify,
+			},
+			{
+				Name:    "reconcile",
+				Aliases: []string{"r"},
+				Usage:   "Compare computed account balances with AbilityCash running balances",
+				Action:  reconcile,
 			},
 		},
 	}
@@ -59,40 +84,88 @@ func add(c *cli.Context) error {
 
 	newPath := c.Args().First()
 
-	ensureFileExist(newPath)
+	if !checkFileExist(newPath) {
+		return fmt.Errorf("file %v does not exist", newPath)
+	}
 
-	if err := config.AddFile(newPath); err == nil {
-		saveScope()
-		return nil
-	} else {
+	if err := config.AddFile(newPath); err != nil {
 		return err
 	}
+
+	return saveScope()
 }
 
 func prepare(c *cli.Context) error {
 	messages, err := config.Validate()
 
+	for _, m := range messages {
+		log.Println(m)
+	}
+
 	if err != nil {
 		return err
 	}
 
+	return saveScope()
+}
+
+func convert(c *cli.Context) error {
+	dryRun := c.Bool("dry-run")
+
+	messages, err := config.Export(scope.ExportOptions{
+		Incremental: c.Bool("incremental"),
+		DryRun:      dryRun,
+	})
+
+	for _, m := range messages {
+		if dryRun {
+			fmt.Println(m)
+		} else {
+			log.Println(m)
+		}
+	}
+
+	if err != nil || dryRun {
+		return err
+	}
+
+	return saveScope()
+}
+
+func verify(c *cli.Context) error {
+	messages, err := config.Verify()
+
 	for _, m := range messages {
 		log.Println(m)
 	}
 
-	saveScope()
+	if err != nil {
+		return err
+	}
+
+	if len(messages) > 0 {
+		return fmt.Errorf("%d problems found", len(messages))
+	}
 
 	return nil
 }
 
-func convert(c *cli.Context) error {
-	return config.Export()
-}
+func reconcile(c *cli.Context) error {
+	messages, err := config.Reconcile()
+
+	if err != nil {
+		return err
+	}
+
+	for _, m := range messages {
+		log.Println(m)
+	}
 
-func ensureFileExist(path string) {
-	if !checkFileExist(path) {
-		log.Fatalf("File %v does not exist\n", path)
+	if len(messages) > 0 {
+		return fmt.Errorf("%d problems found", len(messages))
 	}
+
+	return nil
 }
 
 func checkFileExist(path string) bool {
@@ -100,30 +173,30 @@ func checkFileExist(path string) bool {
 	return !os.IsNotExist(err)
 }
 
-func readScope() {
+func readScope() error {
 	if !checkFileExist(scopeFile) {
-		return
+		return nil
 	}
 
-	readConfig(scopeFile, &config)
+	return readConfig(scopeFile, &config)
 }
 
-func saveScope() {
-	if err := saveConfig(scopeFile, &config); err != nil {
-		log.Fatal(err)
-	}
+func saveScope() error {
+	return saveConfig(scopeFile, &config)
 }
 
-func readConfig(filename string, config interface{}) {
-	data, _ := ioutil.ReadFile(filename)
-
-	err := json.Unmarshal(data, config)
+func readConfig(filename string, config interface{}) error {
+	data, err := ioutil.ReadFile(filename)
 
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	return
+	if err = json.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return nil
 }
 
 func saveConfig(filename string, config interface{}) error {
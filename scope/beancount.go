@@ -0,0 +1,367 @@
+package scope
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+const beancountAdjustment = "Equity:Adjustments"
+
+var beancountRoots = []string{"Assets", "Liabilities", "Equity", "Income", "Expenses"}
+
+type beancountJournal struct {
+	Opens        []beancountOpen
+	Prices       []beancountPrice
+	Pads         []beancountBalance
+	Balances     []beancountBalance
+	Transactions []beancountTransaction
+	Messages     []string
+	balances     map[string]int
+	accounts     beancountNames
+	keys         beancountNames
+}
+
+// beancountNames keeps different source names apart when they are written the same way
+type beancountNames struct {
+	kind    string
+	written map[string]string
+	owners  map[string]string
+}
+
+type beancountOpen struct {
+	Date    time.Time
+	Account string
+}
+
+type beancountPrice struct {
+	Date     time.Time
+	Currency string
+	Amount   ledger.Amount
+	Quote    string
+	Source   string
+}
+
+type beancountBalance struct {
+	Date     time.Time
+	Account  string
+	Source   string
+	Amount   ledger.Amount
+	Currency string
+}
+
+type beancountTransaction struct {
+	ledger.Transaction
+	Postings []beancountPosting
+}
+
+type beancountPosting struct {
+	ledger.TxItem
+	TotalPrice         ledger.Amount
+	TotalPriceCurrency string
+}
+
+func newBeancountJournal(txs <-chan ledger.Transaction) *beancountJournal {
+	j := &beancountJournal{
+		balances: make(map[string]int),
+		accounts: newBeancountNames("account"),
+		keys:     newBeancountNames("metadata key"),
+	}
+	opens := make(map[string]time.Time)
+
+	use := func(account string, date time.Time) string {
+		account = j.name(&j.accounts, account, beancountAccount(account))
+
+		if first, ok := opens[account]; !ok || date.Before(first) {
+			opens[account] = date
+		}
+
+		return account
+	}
+
+	for tx := range txs {
+		tx.Metadata = j.metadata(tx.Metadata)
+
+		for i := range tx.Items {
+			tx.Items[i].Account = use(tx.Items[i].Account, tx.Date)
+			tx.Items[i].Currency = beancountCommodity(tx.Items[i].Currency)
+			tx.Items[i].Metadata = j.metadata(tx.Items[i].Metadata)
+		}
+
+		if j.addBalances(tx) {
+			continue
+		}
+
+		j.Transactions = append(j.Transactions, beancountTransaction{
+			Transaction: tx,
+			Postings:    beancountPostings(tx.Items),
+		})
+	}
+
+	for account, date := range opens {
+		j.Opens = append(j.Opens, beancountOpen{Date: date, Account: account})
+	}
+
+	sort.Slice(j.Opens, func(a, b int) bool {
+		return j.Opens[a].Account < j.Opens[b].Account
+	})
+
+	return j
+}
+
+func (j *beancountJournal) addPrices(rates []schema.Rate) error {
+	for _, rate := range rates {
+		if rate.Amount1.IsZero() {
+			continue
+		}
+
+		amount, err := price(rate)
+		if err != nil {
+			return err
+		}
+
+		j.Prices = append(j.Prices, beancountPrice{
+			Date:     rate.Date,
+			Currency: beancountCommodity(rate.Currency1),
+			Amount:   amount,
+			Quote:    beancountCommodity(rate.Currency2),
+			Source:   rate.Source,
+		})
+	}
+
+	return nil
+}
+
+// addBalances moves balance assertions into balance directives, which beancount checks at the beginning of the day.
+// It reports whether the transaction holds only assertions and must be replaced with pad directives.
+func (j *beancountJournal) addBalances(tx ledger.Transaction) bool {
+	assertionOnly := false
+	source := ""
+
+	for _, item := range tx.Items {
+		if !item.HasBalanceAssertion {
+			if item.Amount.IsZero() {
+				source = item.Account
+			}
+			continue
+		}
+
+		j.addBalance(beancountBalance{
+			Date:     tx.Date.AddDate(0, 0, 1),
+			Account:  item.Account,
+			Amount:   item.BalanceAssertion,
+			Currency: item.Currency,
+		})
+
+		if item.Amount.IsZero() && !item.HasRunningBalance {
+			assertionOnly = true
+		}
+	}
+
+	if !assertionOnly {
+		return false
+	}
+
+	if source == "" {
+		source = beancountAdjustment
+	}
+
+	for _, item := range tx.Items {
+		if !item.HasBalanceAssertion {
+			continue
+		}
+
+		j.Pads = append(j.Pads, beancountBalance{
+			Date:    tx.Date,
+			Account: item.Account,
+			Source:  source,
+		})
+	}
+
+	return true
+}
+
+// addBalance keeps the last balance of the day, beancount can not check the balance between transactions
+func (j *beancountJournal) addBalance(balance beancountBalance) {
+	key := balance.Account + "|" + balance.Currency + "|" + balance.Date.Format("2006-01-02")
+
+	if i, ok := j.balances[key]; ok {
+		j.Balances[i] = balance
+		return
+	}
+
+	j.balances[key] = len(j.Balances)
+	j.Balances = append(j.Balances, balance)
+}
+
+// beancountPostings annotates a two-currency transaction with a total price, otherwise it would not balance
+func beancountPostings(items []ledger.TxItem) []beancountPosting {
+	postings := make([]beancountPosting, len(items))
+
+	for i, item := range items {
+		postings[i].TxItem = item
+	}
+
+	if len(items) != 2 || items[0].Amount.IsZero() || items[1].Amount.IsZero() || items[0].Currency == items[1].Currency {
+		return postings
+	}
+
+	index := 1
+	if items[1].Amount.Sign() < 0 {
+		index = 0
+	}
+
+	postings[index].TotalPrice = items[1-index].Amount.Abs()
+	postings[index].TotalPriceCurrency = items[1-index].Currency
+
+	return postings
+}
+
+func beancountAccount(account string) string {
+	components := make([]string, 0)
+
+	for _, part := range strings.Split(account, ":") {
+		if part = beancountComponent(part); part != "" {
+			components = append(components, part)
+		}
+	}
+
+	if len(components) == 0 || !isBeancountRoot(components[0]) {
+		components = append([]string{"Assets"}, components...)
+	}
+
+	return strings.Join(components, ":")
+}
+
+func isBeancountRoot(component string) bool {
+	for _, root := range beancountRoots {
+		if root == component {
+			return true
+		}
+	}
+
+	return false
+}
+
+func beancountComponent(s string) string {
+	s = strings.Trim(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			return r
+		}
+		return '-'
+	}, s), "-")
+
+	for strings.Contains(s, "--") {
+		s = strings.ReplaceAll(s, "--", "-")
+	}
+
+	if s == "" {
+		return s
+	}
+
+	runes := []rune(s)
+	runes[0] = unicode.ToUpper(runes[0])
+
+	if !unicode.IsUpper(runes[0]) && !unicode.IsDigit(runes[0]) {
+		return "X" + string(runes)
+	}
+
+	return string(runes)
+}
+
+func beancountCommodity(currency string) string {
+	if currency == "" {
+		return currency
+	}
+
+	s := strings.Map(func(r rune) rune {
+		r = unicode.ToUpper(r)
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("'._-", r) {
+			return r
+		}
+		return -1
+	}, currency)
+
+	if s == "" || s[0] < 'A' || s[0] > 'Z' {
+		s = "X" + s
+	}
+
+	return strings.TrimRight(s, "'._-")
+}
+
+func newBeancountNames(kind string) beancountNames {
+	return beancountNames{kind: kind, written: make(map[string]string), owners: make(map[string]string)}
+}
+
+// name returns how the source name is written, a name already written for another source name gets a suffix
+func (j *beancountJournal) name(names *beancountNames, source, name string) string {
+	if written, ok := names.written[source]; ok {
+		return written
+	}
+
+	unique := name
+	for i := 2; names.owners[unique] != ""; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+
+	if unique != name {
+		j.Messages = append(j.Messages, fmt.Sprintf("%s %q is written as %s, %s is %q", names.kind, source, unique, name, names.owners[name]))
+	}
+
+	names.written[source] = unique
+	names.owners[unique] = source
+
+	return unique
+}
+
+// metadata transliterates Cyrillic keys, beancount wants them ASCII, lowercase and at least two characters long
+func (j *beancountJournal) metadata(metadata map[string]string) map[string]string {
+	result := make(map[string]string, len(metadata))
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		result[j.name(&j.keys, key, beancountKey(key))] = metadata[key]
+	}
+
+	return result
+}
+
+func beancountKey(s string) string {
+	key := strings.Builder{}
+
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_':
+			key.WriteRune(r)
+		case cyrillic[r] != "" || r == 'ъ' || r == 'ь':
+			key.WriteString(cyrillic[r])
+		default:
+			key.WriteRune('-')
+		}
+	}
+
+	s = key.String()
+
+	if s == "" || s[0] < 'a' || s[0] > 'z' || len(s) < 2 {
+		s = "x" + s
+	}
+
+	return s
+}
+
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh", 'з': "z", 'и': "i", 'й': "y",
+	'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f",
+	'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ы': "y", 'э': "e", 'ю': "yu", 'я': "ya",
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g",
+}
@@ -0,0 +1,71 @@
+package scope
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+func beancountTxs(txs ...ledger.Transaction) <-chan ledger.Transaction {
+	ch := make(chan ledger.Transaction, len(txs))
+	for _, tx := range txs {
+		ch <- tx
+	}
+	close(ch)
+
+	return ch
+}
+
+func TestBeancountMetadata(t *testing.T) {
+	day := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	journal := newBeancountJournal(beancountTxs(
+		ledger.Transaction{Date: day, Items: []ledger.TxItem{{Account: "Cash"}}, Metadata: map[string]string{"Проект": "Дача", "Клиент": "Иванов", "Ёж": "да"}},
+		ledger.Transaction{Date: day, Items: []ledger.TxItem{{Account: "Cash"}}, Metadata: map[string]string{"proekt": "old", "a": "short"}},
+	))
+
+	want := []map[string]string{
+		{"proekt": "Дача", "klient": "Иванов", "ezh": "да"},
+		{"proekt-2": "old", "xa": "short"},
+	}
+
+	for i, tx := range journal.Transactions {
+		if len(tx.Metadata) != len(want[i]) {
+			t.Errorf("transaction %d metadata = %v, want %v", i, tx.Metadata, want[i])
+			continue
+		}
+
+		for key, value := range want[i] {
+			if tx.Metadata[key] != value {
+				t.Errorf("transaction %d metadata = %v, want %v", i, tx.Metadata, want[i])
+				break
+			}
+		}
+	}
+
+	if len(journal.Messages) != 1 {
+		t.Errorf("got messages %q, want the proekt collision", journal.Messages)
+	}
+}
+
+func TestBeancountAccountCollision(t *testing.T) {
+	day := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	journal := newBeancountJournal(beancountTxs(ledger.Transaction{Date: day, Items: []ledger.TxItem{
+		{Account: "Expenses:Еда и напитки"},
+		{Account: "Expenses:Еда/и/напитки"},
+		{Account: "Expenses:Еда и напитки"},
+	}}))
+
+	want := []string{"Expenses:Еда-и-напитки", "Expenses:Еда-и-напитки-2", "Expenses:Еда-и-напитки"}
+
+	for i, item := range journal.Transactions[0].Items {
+		if item.Account != want[i] {
+			t.Errorf("posting %d account = %s, want %s", i, item.Account, want[i])
+		}
+	}
+
+	if len(journal.Messages) != 1 || len(journal.Opens) != 2 {
+		t.Errorf("got messages %q and %d opens, want one collision and two accounts", journal.Messages, len(journal.Opens))
+	}
+}
@@ -0,0 +1,120 @@
+package scope
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+const (
+	placementPrefix = "prefix"
+	placementSuffix = "suffix"
+)
+
+// commodities maps AbilityCash currency codes to output commodities, codes without a mapping are written as they are
+type commodities map[string]*commodityOutput
+
+type commodityOutput struct {
+	Symbol    string `json:"symbol"`
+	Placement string `json:"placement,omitempty"`
+}
+
+func (c commodities) validate() error {
+	for code, output := range c {
+		switch {
+		case output == nil || output.Symbol == "":
+			return fmt.Errorf("commodity %s has no symbol", code)
+		case output.Placement != "" && output.Placement != placementPrefix && output.Placement != placementSuffix:
+			return fmt.Errorf("commodity %s: unknown placement %q, use %q or %q", code, output.Placement, placementPrefix, placementSuffix)
+		}
+	}
+
+	return nil
+}
+
+// add declares the currencies of a datafile with themselves as symbols, to be edited in scope.json
+func (c commodities) add(currencies []schema.Currency) []string {
+	added := make([]string, 0)
+
+	for _, currency := range currencies {
+		if _, ok := c[currency.Code]; !ok {
+			c[currency.Code] = &commodityOutput{Symbol: currency.Code, Placement: placementSuffix}
+			added = append(added, currency.Code)
+		}
+	}
+
+	return added
+}
+
+func (c commodities) symbol(code string) string {
+	if output, ok := c[code]; ok {
+		code = output.Symbol
+	}
+
+	// a symbol quoted in scope.json is taken as it is
+	if strings.HasPrefix(code, `"`) {
+		return code
+	}
+
+	return commodity(code)
+}
+
+func (c commodities) format(a ledger.Amount, code string, sign bool) string {
+	symbol := c.symbol(code)
+	number := a.String()
+
+	if output, ok := c[code]; !ok || output.Placement != placementPrefix {
+		if sign {
+			number = signed(a)
+		}
+
+		return fmt.Sprintf("%s %s", number, symbol)
+	}
+
+	// "$10" but "USD 10", the minus goes before the symbol: "-$10"
+	if r, _ := utf8.DecodeLastRuneInString(symbol); unicode.IsLetter(r) || r == '"' {
+		symbol += " "
+	}
+
+	switch {
+	case a.Sign() < 0:
+		return "-" + symbol + a.Abs().String()
+	case sign:
+		return " " + symbol + number
+	default:
+		return symbol + number
+	}
+}
+
+// cost is the price annotation of a posting: " @ 250 USD", " @@ 2500 USD" or " {250 USD}"
+func (c commodities) cost(item ledger.TxItem) string {
+	switch item.CostMode {
+	case ledger.UnitCost:
+		return " @ " + c.format(item.Cost, item.CostCurrency, false)
+	case ledger.TotalCost:
+		return " @@ " + c.format(item.Cost, item.CostCurrency, false)
+	case ledger.LotCost:
+		return " {" + c.format(item.Cost, item.CostCurrency, false) + "}"
+	default:
+		return ""
+	}
+}
+
+// funcs replace the commodity template functions with the mapped ones
+func (c commodities) funcs() template.FuncMap {
+	return template.FuncMap{
+		"commodity": c.symbol,
+		"amount": func(a ledger.Amount, code string) string {
+			return c.format(a, code, false)
+		},
+		"signedAmount": func(a ledger.Amount, code string) string {
+			return c.format(a, code, true)
+		},
+		"cost": c.cost,
+	}
+}
@@ -3,23 +3,50 @@ package scope
 import (
 	"errors"
 	"fmt"
-	"github.com/Bishop/abilitycash2ledger/ability_cash/sql_schema"
+	"io"
+	"io/fs"
 	"os"
 	"path"
-	"text/template"
+	"time"
 
 	"github.com/Bishop/abilitycash2ledger/ability_cash"
 	"github.com/Bishop/abilitycash2ledger/ability_cash/csv_schema"
 	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+	"github.com/Bishop/abilitycash2ledger/ability_cash/sql_schema"
+	"github.com/Bishop/abilitycash2ledger/ability_cash/xlsx_schema"
 	"github.com/Bishop/abilitycash2ledger/ability_cash/xml_schema"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+const (
+	outputLedger    = "ledger"
+	outputHledger   = "hledger"
+	outputBeancount = "beancount"
+
+	pricePrecision = 8
 )
 
+// optionalEntities are not written by every dialect or datafile
+var optionalEntities = map[string]bool{"commodities": true, "budget": true}
+
 type datafile struct {
-	Active bool   `json:"active"`
-	Equity bool   `json:"equity"`
-	Path   string `json:"path"`
-	Target string `json:"target"`
-	db     schema.Database
+	Active       bool          `json:"active"`
+	Equity       bool          `json:"equity"`
+	Assertions   bool          `json:"assertions"`
+	Path         string        `json:"path"`
+	Target       string        `json:"target"`
+	Output       string        `json:"output"`
+	LastExported *lastExported `json:"last_exported,omitempty"`
+	db           schema.Database
+	messages     []string
+	memory       *memoryFiles
+	templates    fs.FS
+	commodities  commodities
+}
+
+type ExportOptions struct {
+	Incremental bool
+	DryRun      bool
 }
 
 func (d *datafile) readDb() (schema.Database, error) {
@@ -28,6 +55,8 @@ func (d *datafile) readDb() (schema.Database, error) {
 		return xml_schema.ReadDatabase(d.Path)
 	case "", ".csv":
 		return csv_schema.ReadDatabase(d.Path)
+	case ".xlsx":
+		return xlsx_schema.ReadDatabase(d.Path)
 	case ".cash":
 		return sql_schema.ReadDatabase(d.Path)
 	default:
@@ -35,73 +64,229 @@ func (d *datafile) readDb() (schema.Database, error) {
 	}
 }
 
+func (d *datafile) name() string {
+	if d.Path == "" {
+		return d.Target
+	}
+
+	return d.Path
+}
+
 func (d *datafile) format() string {
 	return path.Ext(d.Path)
 }
 
-func (d *datafile) export(categories map[string]string) (err error) {
-	if err = d.exportEntity("rates", d.db.GetRates()); err != nil {
+func (d *datafile) export(s *scope, options ExportOptions) error {
+	switch d.Output {
+	case "", outputLedger, outputHledger:
+		return d.exportJournal(s, d.Output, options)
+	case outputBeancount:
+		if options.Incremental {
+			return errors.New("incremental export is not supported for beancount output")
+		}
+		return d.exportBeancount(s)
+	default:
+		return errors.New(fmt.Sprintf("unknown output format %s", d.Output))
+	}
+}
+
+// exportJournal writes the ledger or hledger files, they differ only in the account declarations
+func (d *datafile) exportJournal(s *scope, dialect string, options ExportOptions) (err error) {
+	if err = d.exportEntity("commodities", d.db.GetCurrencies()); err != nil {
 		return
 	}
 
-	converter := &ability_cash.LedgerConverter{
-		GenerateEquity: d.Equity,
-		Db:             d.db,
-		Categories:     categories,
+	converter := d.converter(s)
+
+	if err = d.exportTxs(converter, options); err != nil {
+		return
 	}
 
-	err = d.exportEntity("txs", converter.Transactions())
+	if err = d.exportBudget(converter); err != nil {
+		return
+	}
 
-	if err = d.exportEntity("accounts", converter.Accounts()); err != nil {
-		return err
+	// exchanges add prices, so rates follow transactions
+	if err = d.exportEntity("rates", converter.Rates()); err != nil {
+		return
+	}
+
+	if dialect == outputHledger {
+		return d.exportEntity("accounts", hledgerAccounts(converter))
 	}
 
-	return
+	return d.exportEntity("accounts", converter.Accounts())
 }
 
-func (d *datafile) exportEntity(entityName string, data interface{}) error {
-	t, err := getTemplate(entityName)
+func (d *datafile) exportBeancount(s *scope) error {
+	converter := d.converter(s)
 
-	if err != nil {
+	journal := newBeancountJournal(d.transactions(converter))
+
+	if err := converter.Err(); err != nil {
 		return err
 	}
 
-	file, err := os.Create(fmt.Sprintf("%s-%s.journal", d.Target, entityName))
+	if err := journal.addPrices(converter.Rates()); err != nil {
+		return err
+	}
+
+	d.messages = append(d.messages, journal.Messages...)
+
+	return d.writeFile(fmt.Sprintf("%s.beancount", d.Target), os.O_TRUNC, outputBeancount, journal)
+}
 
+// exportTxs writes all transactions, or appends only new and changed ones to a dated file in the incremental mode
+func (d *datafile) exportTxs(converter *ability_cash.LedgerConverter, options ExportOptions) (err error) {
+	state, err := d.readState()
 	if err != nil {
-		return err
+		return
 	}
 
-	err = t.Execute(file, data)
+	converter.Observe = state.observe
+	txs := state.track(d.transactions(converter), options.Incremental)
+
+	if options.Incremental {
+		// new and changed transactions are collected first, nothing is appended if the datafile has no ids
+		collected := make([]ledger.Transaction, 0)
+		for tx := range txs {
+			collected = append(collected, tx)
+		}
+
+		if err = converter.Err(); err != nil {
+			return
+		}
+
+		if err = state.err; err != nil {
+			return
+		}
+
+		fileName := fmt.Sprintf("%s-txs-%s.journal", d.Target, time.Now().Format("2006-01-02"))
+		if err = d.writeFile(fileName, os.O_APPEND, "txs", collected); err != nil {
+			return
+		}
+
+		d.messages = append(d.messages, state.report()...)
+	} else {
+		if err = d.exportEntity("txs", txs); err != nil {
+			return
+		}
+
+		if err = converter.Err(); err != nil {
+			return
+		}
+	}
+
+	if options.DryRun {
+		return
+	}
+
+	return d.saveState(state)
+}
+
+// exportBudget writes scheduled transactions as periodic ones, hledger compares them with the actual ones
+func (d *datafile) exportBudget(converter *ability_cash.LedgerConverter) error {
+	budget := converter.Budget()
+
+	if len(budget) == 0 {
+		return nil
+	}
+
+	return d.exportEntity("budget", budget)
+}
+
+// readJournal reads the written files in the order ledger needs them: declarations first
+func (d *datafile) readJournal() (*ledger.Journal, error) {
+	journal := ledger.NewJournal()
+
+	for _, entity := range []string{"accounts", "commodities", "rates", "txs", "budget"} {
+		fileName := fmt.Sprintf("%s-%s.journal", d.Target, entity)
+
+		if _, err := os.Stat(fileName); optionalEntities[entity] && os.IsNotExist(err) {
+			continue
+		}
+
+		if err := journal.ReadFile(fileName); err != nil {
+			return nil, err
+		}
+	}
+
+	return journal, nil
+}
+
+func (d *datafile) converter(s *scope) *ability_cash.LedgerConverter {
+	return &ability_cash.LedgerConverter{
+		GenerateEquity:  d.Equity,
+		Assertions:      d.Assertions,
+		Db:              d.db,
+		Categories:      s.Categories,
+		FallbackAccount: s.Fallback,
+		Rules:           s.Rules,
+		Costs:           s.Costs,
+		BaseCurrency:    s.BaseCurrency,
+	}
+}
+
+// reportProblems adds the records skipped by the reader, the list is complete after transactions are read
+func (d *datafile) reportProblems() {
+	for _, problem := range schema.Problems(d.db) {
+		d.messages = append(d.messages, fmt.Sprintf("skipped %s", problem))
+	}
+}
+
+func (d *datafile) transactions(converter *ability_cash.LedgerConverter) <-chan ledger.Transaction {
+	if d.memory != nil {
+		return d.memory.track(converter.Transactions())
+	}
+
+	return converter.Transactions()
+}
+
+func (d *datafile) exportEntity(entityName string, data interface{}) error {
+	return d.writeFile(fmt.Sprintf("%s-%s.journal", d.Target, entityName), os.O_TRUNC, entityName, data)
+}
+
+func (d *datafile) writeFile(fileName string, flag int, templateName string, data interface{}) error {
+	if d.memory != nil {
+		buffer, err := d.memory.buffer(fileName, flag, templateName)
+
+		if err != nil {
+			return err
+		}
+
+		return d.render(buffer, templateName, data)
+	}
+
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|flag, 0666)
 
 	if err != nil {
 		return err
 	}
 
-	err = file.Close()
+	err = d.render(file, templateName, data)
 
 	if err != nil {
+		_ = file.Close()
 		return err
 	}
 
-	return nil
+	return file.Close()
 }
 
-func getTemplate(name string) (*template.Template, error) {
-	return template.New(fmt.Sprintf("%s.go.tmpl", name)).
-		Funcs(template.FuncMap{
-			"acc":    acc,
-			"signed": signed,
-		}).
-		ParseFiles(fmt.Sprintf("templates/%s.go.tmpl", name))
-}
+func (d *datafile) render(w io.Writer, templateName string, data interface{}) error {
+	t, err := getTemplate(d.templates, d.commodities.funcs(), d.dialect(), templateName)
+
+	if err != nil {
+		return err
+	}
 
-func acc(account string) string {
-	return fmt.Sprintf("%-40s", account)
+	return t.Execute(w, data)
 }
 
-func signed(amount float64) string {
-	// suppress exponent format floats
-	// print 110778000, not 1.10778e+08, and not 110778000.000000
-	return fmt.Sprintf("% .10g", amount)
+func (d *datafile) dialect() string {
+	if d.Output == outputHledger {
+		return outputHledger
+	}
+
+	return ""
 }
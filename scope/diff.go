@@ -0,0 +1,213 @@
+package scope
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+type edit struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff returns an empty string for equal texts
+func unifiedDiff(name string, before, after string) string {
+	if before == after {
+		return ""
+	}
+
+	edits := diffLines(splitLines(before), splitLines(after))
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "--- %s\n+++ %s (dry run)\n", name, name)
+
+	for start := 0; start < len(edits); {
+		for start < len(edits) && edits[start].kind == ' ' {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+
+		// extend the hunk while changes are closer than two contexts
+		end, equal := start, 0
+		for i := start; i < len(edits) && equal <= 2*diffContext; i++ {
+			if edits[i].kind == ' ' {
+				equal++
+			} else {
+				end, equal = i+1, 0
+			}
+		}
+
+		from, to := start-diffContext, end+diffContext
+		if from < 0 {
+			from = 0
+		}
+		if to > len(edits) {
+			to = len(edits)
+		}
+
+		writeHunk(&b, edits, from, to)
+		start = to
+	}
+
+	return b.String()
+}
+
+func writeHunk(b *strings.Builder, edits []edit, from, to int) {
+	beforeStart, afterStart := 1, 1
+
+	for _, e := range edits[:from] {
+		if e.kind != '+' {
+			beforeStart++
+		}
+		if e.kind != '-' {
+			afterStart++
+		}
+	}
+
+	beforeCount, afterCount := 0, 0
+
+	for _, e := range edits[from:to] {
+		if e.kind != '+' {
+			beforeCount++
+		}
+		if e.kind != '-' {
+			afterCount++
+		}
+	}
+
+	// an empty range points to the line before it
+	if beforeCount == 0 {
+		beforeStart--
+	}
+	if afterCount == 0 {
+		afterStart--
+	}
+
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", beforeStart, beforeCount, afterStart, afterCount)
+
+	for _, e := range edits[from:to] {
+		fmt.Fprintf(b, "%c%s\n", e.kind, e.line)
+	}
+}
+
+// diffLines is the linear space Myers algorithm: the middle snake of the edit script splits it in two halves,
+// which are compared the same way. The common head and tail are cut off first.
+func diffLines(a, b []string) []edit {
+	return myers(make([]edit, 0, len(a)+len(b)), a, b)
+}
+
+func myers(edits []edit, a, b []string) []edit {
+	head := 0
+	for head < len(a) && head < len(b) && a[head] == b[head] {
+		head++
+	}
+
+	tail := 0
+	for tail < len(a)-head && tail < len(b)-head && a[len(a)-1-tail] == b[len(b)-1-tail] {
+		tail++
+	}
+
+	for _, line := range a[:head] {
+		edits = append(edits, edit{' ', line})
+	}
+
+	middleA, middleB := a[head:len(a)-tail], b[head:len(b)-tail]
+
+	switch {
+	case len(middleA) == 0:
+		for _, line := range middleB {
+			edits = append(edits, edit{'+', line})
+		}
+	case len(middleB) == 0:
+		for _, line := range middleA {
+			edits = append(edits, edit{'-', line})
+		}
+	default:
+		// both sides differ at the ends, so the script has at least two edits and both halves are shorter
+		x, y, u, v := middleSnake(middleA, middleB)
+
+		edits = myers(edits, middleA[:x], middleB[:y])
+		for _, line := range middleA[x:u] {
+			edits = append(edits, edit{' ', line})
+		}
+		edits = myers(edits, middleA[u:], middleB[v:])
+	}
+
+	for _, line := range a[len(a)-tail:] {
+		edits = append(edits, edit{' ', line})
+	}
+
+	return edits
+}
+
+// middleSnake runs the search from both ends until the paths meet, the snake from (x, y) to (u, v)
+// lies on an optimal path. The backward search counts x and y from the ends.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	limit := (n + m + 1) / 2
+	offset := limit + 1
+
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			if k == -d || k != d && forward[offset+k-1] < forward[offset+k+1] {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[u] == b[v] {
+				u, v = u+1, v+1
+			}
+
+			forward[offset+k] = u
+
+			// the backward diagonal of k is delta-k, it was searched d-1 times
+			if r := delta - k; odd && r >= -(d-1) && r <= d-1 && u+backward[offset+r] >= n {
+				return x, y, u, v
+			}
+		}
+
+		for r := -d; r <= d; r += 2 {
+			var rx int
+			if r == -d || r != d && backward[offset+r-1] < backward[offset+r+1] {
+				rx = backward[offset+r+1]
+			} else {
+				rx = backward[offset+r-1] + 1
+			}
+
+			ry := rx - r
+			sx, sy := rx, ry
+			for rx < n && ry < m && a[n-1-rx] == b[m-1-ry] {
+				rx, ry = rx+1, ry+1
+			}
+
+			backward[offset+r] = rx
+
+			if k := delta - r; !odd && k >= -d && k <= d && forward[offset+k]+rx >= n {
+				return n - rx, m - ry, n - sx, m - sy
+			}
+		}
+	}
+
+	return 0, 0, 0, 0
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
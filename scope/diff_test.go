@@ -0,0 +1,157 @@
+package scope
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// sides rebuilds both texts from the edit script
+func sides(edits []edit) ([]string, []string) {
+	a, b := make([]string, 0), make([]string, 0)
+
+	for _, e := range edits {
+		if e.kind != '+' {
+			a = append(a, e.line)
+		}
+		if e.kind != '-' {
+			b = append(b, e.line)
+		}
+	}
+
+	return a, b
+}
+
+func changes(edits []edit) int {
+	count := 0
+
+	for _, e := range edits {
+		if e.kind != ' ' {
+			count++
+		}
+	}
+
+	return count
+}
+
+// shortest is the length of the shortest edit script, by the longest common subsequence
+func shortest(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] > lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func checkDiff(t *testing.T, a, b []string, want int) {
+	t.Helper()
+
+	edits := diffLines(a, b)
+	gotA, gotB := sides(edits)
+
+	if strings.Join(gotA, "\n") != strings.Join(a, "\n") || strings.Join(gotB, "\n") != strings.Join(b, "\n") {
+		t.Fatalf("diff of %q and %q does not rebuild them: %q", a, b, edits)
+	}
+
+	if got := changes(edits); got != want {
+		t.Errorf("diff of %d and %d lines has %d changes, want %d", len(a), len(b), got, want)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "a b c", 3},
+		{"a b c", "", 3},
+		{"a b c", "a b c", 0},
+		{"a b c", "a x c", 2},
+		{"a b c a b b a", "c b a b a c", 5},
+		{"a", "b", 2},
+		{"a b", "b a", 2},
+		{"x a b c", "a b c x", 2},
+	}
+
+	for _, tt := range tests {
+		checkDiff(t, strings.Fields(tt.a), strings.Fields(tt.b), tt.want)
+	}
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	text := func() []string {
+		lines := make([]string, random.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + random.Intn(3)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := text(), text()
+		checkDiff(t, a, b, shortest(a, b))
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	lines := make([]string, 20000)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("    Expenses:Food  %d.00 RUB", i)
+	}
+
+	checkDiff(t, nil, lines, len(lines))
+	checkDiff(t, lines, nil, len(lines))
+
+	changed := append([]string(nil), lines...)
+	for i := 50; i < len(changed); i += 100 {
+		changed[i] += " ; edited"
+	}
+
+	checkDiff(t, lines, changed, 2*len(lines)/100)
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	after := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n"
+
+	want := `--- txs.journal
++++ txs.journal (dry run)
+@@ -2,9 +2,10 @@
+ b
+ c
+ d
+-e
++E
+ f
+ g
+ h
+ i
+ j
++k
+`
+
+	if got := unifiedDiff("txs.journal", before, after); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if got := unifiedDiff("txs.journal", before, before); got != "" {
+		t.Errorf("equal texts give %q", got)
+	}
+}
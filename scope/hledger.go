@@ -0,0 +1,19 @@
+package scope
+
+import "github.com/Bishop/abilitycash2ledger/ability_cash"
+
+type hledgerAccount struct {
+	Name string
+	Type string
+}
+
+func hledgerAccounts(converter *ability_cash.LedgerConverter) []hledgerAccount {
+	types := converter.AccountTypes()
+	accounts := make([]hledgerAccount, 0)
+
+	for _, account := range converter.Accounts() {
+		accounts = append(accounts, hledgerAccount{Name: account, Type: types[account]})
+	}
+
+	return accounts
+}
@@ -0,0 +1,191 @@
+package scope
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+// lastExported is the most recently changed transaction of the export, the last one in the datafile if
+// the datafile has no change times
+type lastExported struct {
+	Id         string    `json:"id"`
+	ChangedAt  time.Time `json:"changed_at,omitempty"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// exportState keeps a hash of every exported transaction, so the next run can tell new and changed ones.
+// Transactions are hashed as the datafile has them, so a change of the conversion settings changes nothing.
+type exportState struct {
+	Hashes  map[string]string `json:"hashes"`
+	last    lastExported
+	changed []ledger.Transaction
+	added   int
+	err     error
+
+	// sources are the hashes of source transactions waiting for their converted ones
+	sources map[string]string
+	mutex   sync.Mutex
+}
+
+func (d *datafile) stateFile() string {
+	return fmt.Sprintf("%s-exported.json", d.Target)
+}
+
+func (d *datafile) readState() (*exportState, error) {
+	state := &exportState{Hashes: make(map[string]string), sources: make(map[string]string)}
+
+	data, err := ioutil.ReadFile(d.stateFile())
+
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func (d *datafile) saveState(state *exportState) error {
+	data, err := json.Marshal(state)
+
+	if err != nil {
+		return err
+	}
+
+	if state.last.Id != "" {
+		last := state.last
+		last.ExportedAt = time.Now()
+		d.LastExported = &last
+	}
+
+	return ioutil.WriteFile(d.stateFile(), data, 0600)
+}
+
+// observe hashes a source transaction, it runs in the converter
+func (s *exportState) observe(tx ledger.Transaction) {
+	if tx.Id == "" {
+		return
+	}
+
+	hash := hashTransaction(tx)
+
+	s.mutex.Lock()
+	s.sources[tx.Id] = hash
+	s.mutex.Unlock()
+}
+
+// sourceHash returns the hash of the source of a converted transaction, generated ones are hashed as they are
+func (s *exportState) sourceHash(tx ledger.Transaction) string {
+	s.mutex.Lock()
+	hash, ok := s.sources[tx.Id]
+	delete(s.sources, tx.Id)
+	s.mutex.Unlock()
+
+	if !ok {
+		return hashTransaction(tx)
+	}
+
+	return hash
+}
+
+// track remembers passing transactions, in the incremental mode only new and changed ones pass
+func (s *exportState) track(txs <-chan ledger.Transaction, incremental bool) <-chan ledger.Transaction {
+	result := make(chan ledger.Transaction)
+
+	go func() {
+		defer close(result)
+
+		for tx := range txs {
+			if tx.Id == "" {
+				if incremental && s.err == nil {
+					s.err = errors.New("transactions have no identifiers, incremental export is not possible")
+				}
+				if !incremental {
+					result <- tx
+				}
+				continue
+			}
+
+			hash := s.sourceHash(tx)
+			previous, known := s.Hashes[tx.Id]
+			s.Hashes[tx.Id] = hash
+
+			// the generated opening balance is not a transaction of the datafile
+			if tx.Id != ability_cash.OpeningBalanceId && (!tx.ChangedAt.Before(s.last.ChangedAt) || s.last.Id == "") {
+				s.last.Id, s.last.ChangedAt = tx.Id, tx.ChangedAt
+			}
+
+			switch {
+			case !incremental:
+				result <- tx
+			case !known:
+				s.added++
+				result <- tx
+			case previous != hash:
+				s.changed = append(s.changed, tx)
+				result <- tx
+			}
+		}
+	}()
+
+	return result
+}
+
+func (s *exportState) report() []string {
+	messages := make([]string, 0, len(s.changed)+1)
+
+	for _, tx := range s.changed {
+		messages = append(messages, fmt.Sprintf("changed: %s", describe(tx)))
+	}
+
+	return append(messages, fmt.Sprintf("%d new, %d changed transactions", s.added, len(s.changed)))
+}
+
+// hashTransaction hashes what the transaction says, not how it is stored: amounts are normalized, so a change
+// of the currency precision does not change the hash. Running balances are left out, they follow every change
+// of earlier transactions.
+func hashTransaction(tx ledger.Transaction) string {
+	lines := []string{tx.Date.Format("2006-01-02"), tx.Payee, tx.Note, strings.Join(tx.Tags, "\x1f")}
+
+	for _, item := range tx.Items {
+		fields := []string{item.Account, item.Currency, item.Amount.Normalize().String(), item.Note, strings.Join(item.Tags, "\x1f")}
+
+		if item.HasBalanceAssertion {
+			fields = append(fields, "="+item.BalanceAssertion.Normalize().String())
+		}
+
+		lines = append(lines, strings.Join(fields, "\x1e"))
+	}
+
+	sum := sha1.Sum([]byte(strings.Join(lines, "\n")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func describe(tx ledger.Transaction) string {
+	description := tx.Date.Format("2006-01-02")
+
+	for _, part := range []string{tx.Payee, tx.Note} {
+		if part != "" {
+			description += " " + part
+		}
+	}
+
+	return fmt.Sprintf("%s (id %s)", description, tx.Id)
+}
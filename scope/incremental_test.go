@@ -0,0 +1,111 @@
+package scope
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+// export runs the converted transactions through the state, each one is observed as its source first
+func export(state *exportState, sources, converted []ledger.Transaction) []ledger.Transaction {
+	txs := make(chan ledger.Transaction)
+
+	go func() {
+		for i := range sources {
+			state.observe(sources[i])
+			txs <- converted[i]
+		}
+		close(txs)
+	}()
+
+	passed := make([]ledger.Transaction, 0)
+	for tx := range state.track(txs, true) {
+		passed = append(passed, tx)
+	}
+
+	return passed
+}
+
+func TestTrackHashesSources(t *testing.T) {
+	day := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	sources := []ledger.Transaction{
+		{Id: "1", Note: "first", ChangedAt: day.Add(time.Hour)},
+		{Id: "2", Note: "second", ChangedAt: day},
+	}
+	converted := []ledger.Transaction{{Id: "1", Payee: "Shop", ChangedAt: day.Add(time.Hour)}, {Id: "2", Payee: "Shop", ChangedAt: day}}
+
+	state := &exportState{Hashes: make(map[string]string), sources: make(map[string]string)}
+	if passed := export(state, sources, converted); len(passed) != 2 || state.added != 2 {
+		t.Fatalf("first export passed %d, added %d; want 2, 2", len(passed), state.added)
+	}
+
+	if state.last.Id != "1" || !state.last.ChangedAt.Equal(day.Add(time.Hour)) {
+		t.Errorf("last exported = %s %s, want the most recently changed 1", state.last.Id, state.last.ChangedAt)
+	}
+
+	// another payee comes from the settings, the source is the same
+	state = &exportState{Hashes: state.Hashes, sources: make(map[string]string)}
+	converted = []ledger.Transaction{{Id: "1", Payee: "Market"}, {Id: "2", Payee: "Market"}}
+	if passed := export(state, sources, converted); len(passed) != 0 {
+		t.Errorf("a settings change passed %d transactions, want none", len(passed))
+	}
+
+	state = &exportState{Hashes: state.Hashes, sources: make(map[string]string)}
+	sources[1].Note = "edited"
+	if passed := export(state, sources, converted); len(passed) != 1 || len(state.changed) != 1 || passed[0].Id != "2" {
+		t.Errorf("an edited source passed %v, changed %d; want 2", passed, len(state.changed))
+	}
+}
+
+func TestTrackNeedsIds(t *testing.T) {
+	state := &exportState{Hashes: make(map[string]string), sources: make(map[string]string)}
+	tx := ledger.Transaction{Note: "no id"}
+
+	if passed := export(state, []ledger.Transaction{tx}, []ledger.Transaction{tx}); len(passed) != 0 || state.err == nil {
+		t.Errorf("passed %d, error %v; want none and an error", len(passed), state.err)
+	}
+}
+
+func TestHashTransaction(t *testing.T) {
+	day := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	tx := ledger.Transaction{Id: "1", Date: day, Note: "food", Tags: []string{"Expenses\\Food"}, Items: []ledger.TxItem{
+		{Account: "Cash", Currency: "RUB", Amount: ledger.NewAmount(-10050, 2), RunningBalance: ledger.NewAmount(90000, 2), HasRunningBalance: true},
+	}}
+	hash := hashTransaction(tx)
+
+	change := func(f func(tx *ledger.Transaction)) string {
+		changed := tx
+		changed.Items = append([]ledger.TxItem(nil), tx.Items...)
+		f(&changed)
+		return hashTransaction(changed)
+	}
+
+	same := map[string]func(tx *ledger.Transaction){
+		"precision":       func(tx *ledger.Transaction) { tx.Items[0].Amount = ledger.NewAmount(-100500, 3) },
+		"running balance": func(tx *ledger.Transaction) { tx.Items[0].RunningBalance = ledger.NewAmount(1, 0) },
+		"change time":     func(tx *ledger.Transaction) { tx.ChangedAt = day.Add(time.Hour) },
+	}
+
+	for name, f := range same {
+		if change(f) != hash {
+			t.Errorf("%s changes the hash", name)
+		}
+	}
+
+	different := map[string]func(tx *ledger.Transaction){
+		"amount":   func(tx *ledger.Transaction) { tx.Items[0].Amount = ledger.NewAmount(-10051, 2) },
+		"account":  func(tx *ledger.Transaction) { tx.Items[0].Account = "Card" },
+		"currency": func(tx *ledger.Transaction) { tx.Items[0].Currency = "USD" },
+		"date":     func(tx *ledger.Transaction) { tx.Date = day.AddDate(0, 0, 1) },
+		"note":     func(tx *ledger.Transaction) { tx.Note = "drinks" },
+		"payee":    func(tx *ledger.Transaction) { tx.Payee = "Shop" },
+		"category": func(tx *ledger.Transaction) { tx.Tags = []string{"Expenses\\Drinks"} },
+	}
+
+	for name, f := range different {
+		if change(f) == hash {
+			t.Errorf("%s keeps the hash", name)
+		}
+	}
+}
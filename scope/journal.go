@@ -0,0 +1,134 @@
+package scope
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+const (
+	journalInclude  = "include"
+	journalCombined = "combined"
+
+	defaultJournal = "main.journal"
+)
+
+// declarations are merged from all datafiles in the order ledger needs them, transactions follow
+var journalSections = []string{"accounts", "commodities", "rates"}
+
+// journalOutput joins ledger and hledger datafiles: into one combined file or into a main file with includes
+type journalOutput struct {
+	Mode string `json:"mode"`
+	Path string `json:"path,omitempty"`
+}
+
+func (j *journalOutput) path() string {
+	if j.Path == "" {
+		return defaultJournal
+	}
+
+	return j.Path
+}
+
+func (j *journalOutput) validate(options ExportOptions) error {
+	switch {
+	case j == nil:
+		return nil
+	case j.Mode != journalInclude && j.Mode != journalCombined:
+		return fmt.Errorf("unknown journal mode %q, use %q or %q", j.Mode, journalInclude, journalCombined)
+	case options.Incremental:
+		return errors.New("incremental export writes separate files, it does not work with the journal setting")
+	default:
+		return nil
+	}
+}
+
+func (j *journalOutput) merge(datafiles []*memoryFiles, output *memoryFiles) {
+	main := new(strings.Builder)
+	base := strings.TrimSuffix(j.path(), filepath.Ext(j.path()))
+
+	for _, section := range journalSections {
+		entries := mergeEntries(datafiles, section)
+
+		if len(entries) == 0 {
+			continue
+		}
+
+		if j.Mode == journalCombined {
+			fmt.Fprintf(main, "%s\n", strings.Join(entries, ""))
+			continue
+		}
+
+		fileName := fmt.Sprintf("%s-%s.journal", base, section)
+		output.put(fileName, strings.Join(entries, ""))
+		j.include(main, fileName)
+	}
+
+	for _, section := range []string{"txs", "budget"} {
+		for _, files := range datafiles {
+			for _, fileName := range files.byTemplate(section) {
+				if j.Mode == journalCombined {
+					main.WriteString(files.rendered[fileName].String())
+					continue
+				}
+
+				output.put(fileName, files.rendered[fileName].String())
+				j.include(main, fileName)
+			}
+		}
+	}
+
+	output.put(j.path(), main.String())
+}
+
+func (j *journalOutput) include(main *strings.Builder, fileName string) {
+	if relative, err := filepath.Rel(filepath.Dir(j.path()), fileName); err == nil {
+		fileName = relative
+	}
+
+	fmt.Fprintf(main, "include %s\n", filepath.ToSlash(fileName))
+}
+
+// mergeEntries drops repeated declarations of an account, a commodity or a price of the day, the first datafile
+// in the list wins; an entry is a line with the indented lines after it
+func mergeEntries(datafiles []*memoryFiles, section string) []string {
+	seen := make(map[string]bool)
+	entries := make([]string, 0)
+
+	for _, files := range datafiles {
+		for _, fileName := range files.byTemplate(section) {
+			for _, entry := range splitEntries(files.rendered[fileName].String()) {
+				key := ledger.DeclarationKey(strings.SplitN(entry, "\n", 2)[0])
+
+				if !seen[key] {
+					seen[key] = true
+					entries = append(entries, entry)
+				}
+			}
+		}
+	}
+
+	sort.Strings(entries)
+
+	return entries
+}
+
+func splitEntries(content string) []string {
+	entries := make([]string, 0)
+
+	for _, line := range splitLines(content) {
+		switch {
+		case strings.TrimSpace(line) == "":
+		case (line[0] == ' ' || line[0] == '\t') && len(entries) > 0:
+			entries[len(entries)-1] += line + "\n"
+		default:
+			entries = append(entries, line+"\n")
+		}
+	}
+
+	return entries
+}
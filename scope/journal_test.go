@@ -0,0 +1,70 @@
+package scope
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func rendered(t *testing.T, section string, contents ...string) []*memoryFiles {
+	t.Helper()
+
+	datafiles := make([]*memoryFiles, len(contents))
+
+	for i, content := range contents {
+		datafiles[i] = newMemoryFiles()
+
+		buffer, err := datafiles[i].buffer(section+".journal", os.O_TRUNC, section)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buffer.WriteString(content)
+	}
+
+	return datafiles
+}
+
+func TestMergeEntries(t *testing.T) {
+	tests := []struct {
+		section  string
+		contents []string
+		want     string
+	}{
+		{
+			section: "accounts",
+			contents: []string{
+				"account Assets:Cash  ; type: A\naccount Expenses:Food\n",
+				"account Assets:Cash\naccount Assets:Card  ; type: A\n",
+			},
+			want: "account Assets:Card  ; type: A\naccount Assets:Cash  ; type: A\naccount Expenses:Food\n",
+		},
+		{
+			section: "commodities",
+			contents: []string{
+				"commodity ₽\n    format 1000.00 ₽\ncommodity \"JPY 2\"\n",
+				"commodity ₽\n    format 1000 ₽\ncommodity $\n    format $1000.00\n",
+			},
+			want: "commodity \"JPY 2\"\ncommodity $\n    format $1000.00\ncommodity ₽\n    format 1000.00 ₽\n",
+		},
+		{
+			section:  "commodities",
+			contents: []string{"commodity 1000.00 ₽\n", "commodity 1000 ₽\ncommodity $1000.00\n"},
+			want:     "commodity $1000.00\ncommodity 1000.00 ₽\n",
+		},
+		{
+			section: "rates",
+			contents: []string{
+				"P 2011-01-01 $ 30.5 ₽\nP 2011-02-04 $ 30.5 ₽  ; exchange Card -> Wallet USD\n",
+				"P 2011-02-04 $ 30.50 ₽\nP 2011-02-05 $ 31 ₽\n",
+			},
+			want: "P 2011-01-01 $ 30.5 ₽\nP 2011-02-04 $ 30.5 ₽  ; exchange Card -> Wallet USD\nP 2011-02-05 $ 31 ₽\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := strings.Join(mergeEntries(rendered(t, tt.section, tt.contents...), tt.section), ""); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.section, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package scope
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+)
+
+// memoryFiles keeps rendered files in memory: for a dry run or to merge them into one journal
+type memoryFiles struct {
+	files     []string
+	rendered  map[string]*bytes.Buffer
+	templates map[string]string
+	summary   summary
+}
+
+type summary struct {
+	count     int
+	first     time.Time
+	last      time.Time
+	transfers int
+	exchanges int
+	accounts  map[string]bool
+}
+
+func newMemoryFiles() *memoryFiles {
+	return &memoryFiles{
+		rendered:  make(map[string]*bytes.Buffer),
+		templates: make(map[string]string),
+		summary:   summary{accounts: make(map[string]bool)},
+	}
+}
+
+// buffer starts with the current content of the file when it is appended to
+func (r *memoryFiles) buffer(fileName string, flag int, templateName string) (*bytes.Buffer, error) {
+	r.templates[fileName] = templateName
+
+	buffer, ok := r.rendered[fileName]
+
+	if ok {
+		if flag&os.O_APPEND == 0 {
+			buffer.Reset()
+		}
+
+		return buffer, nil
+	}
+
+	buffer = new(bytes.Buffer)
+
+	if flag&os.O_APPEND != 0 {
+		data, err := ioutil.ReadFile(fileName)
+
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		buffer.Write(data)
+	}
+
+	r.files = append(r.files, fileName)
+	r.rendered[fileName] = buffer
+
+	return buffer, nil
+}
+
+func (r *memoryFiles) track(txs <-chan ledger.Transaction) <-chan ledger.Transaction {
+	result := make(chan ledger.Transaction)
+
+	go func() {
+		defer close(result)
+
+		for tx := range txs {
+			r.summary.add(tx)
+			result <- tx
+		}
+	}()
+
+	return result
+}
+
+func (r *memoryFiles) put(fileName string, content string) {
+	buffer, _ := r.buffer(fileName, os.O_TRUNC, "")
+	buffer.WriteString(content)
+}
+
+// byTemplate returns rendered files in the order they were written
+func (r *memoryFiles) byTemplate(templateName string) []string {
+	files := make([]string, 0)
+
+	for _, fileName := range r.files {
+		if r.templates[fileName] == templateName {
+			files = append(files, fileName)
+		}
+	}
+
+	return files
+}
+
+func (r *memoryFiles) flush() error {
+	for _, fileName := range r.files {
+		if err := ioutil.WriteFile(fileName, r.rendered[fileName].Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// diffs compares rendered files with the ones on disk
+func (r *memoryFiles) diffs() ([]string, error) {
+	messages := make([]string, 0, len(r.files))
+
+	for _, fileName := range r.files {
+		before, err := ioutil.ReadFile(fileName)
+
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if diff := unifiedDiff(fileName, string(before), r.rendered[fileName].String()); diff != "" {
+			messages = append(messages, diff)
+		} else {
+			messages = append(messages, fmt.Sprintf("%s: no changes", fileName))
+		}
+	}
+
+	return messages, nil
+}
+
+func (s *summary) add(tx ledger.Transaction) {
+	s.count++
+
+	for _, item := range tx.Items {
+		s.accounts[item.Account] = true
+	}
+
+	switch tx.Payee {
+	case "Transfer":
+		s.transfers++
+	case "Exchange":
+		s.exchanges++
+	}
+
+	if tx.Id == ability_cash.OpeningBalanceId {
+		return
+	}
+
+	if s.first.IsZero() || tx.Date.Before(s.first) {
+		s.first = tx.Date
+	}
+
+	if tx.Date.After(s.last) {
+		s.last = tx.Date
+	}
+}
+
+func (s *summary) String() string {
+	period := ""
+
+	if !s.first.IsZero() {
+		period = fmt.Sprintf(" from %s to %s", s.first.Format("2006-01-02"), s.last.Format("2006-01-02"))
+	}
+
+	return fmt.Sprintf("%d transactions%s, %d accounts, %d transfers, %d exchanges",
+		s.count, period, len(s.accounts), s.transfers, s.exchanges)
+}
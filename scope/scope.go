@@ -1,23 +1,57 @@
 package scope
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 	"path"
 	"strings"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash"
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+	"github.com/Bishop/abilitycash2ledger/ledger"
 )
 
 func NewScope() *scope {
 	s := new(scope)
 
 	s.Categories = map[string]string{"Payee": "payee", "Expenses": "account", "Income": "account"}
+	s.Fallback = ledger.Unknown
+	s.Rules = ability_cash.DefaultRules()
 
 	return s
 }
 
 type scope struct {
-	Datafiles  []*datafile       `json:"datafiles"`
-	Categories map[string]string `json:"categories"`
+	Datafiles    []*datafile              `json:"datafiles"`
+	Categories   map[string]string        `json:"categories"`
+	Fallback     string                   `json:"fallback"`
+	Rules        []*ability_cash.Rule     `json:"rules"`
+	Commodities  commodities              `json:"commodities,omitempty"`
+	Costs        []*ability_cash.CostRule `json:"costs,omitempty"`
+	BaseCurrency string                   `json:"base_currency,omitempty"`
+	Templates    string                   `json:"templates,omitempty"`
+	Journal      *journalOutput           `json:"journal,omitempty"`
+	Merge        *datafile                `json:"merge,omitempty"`
+}
+
+// UnmarshalJSON replaces the default rules instead of merging the configured ones into them
+func (s *scope) UnmarshalJSON(data []byte) error {
+	type plain scope
+
+	s.Rules = nil
+
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+
+	if s.Rules == nil {
+		s.Rules = ability_cash.DefaultRules()
+	}
+
+	return nil
 }
 
 func (s *scope) AddFile(name string) error {
@@ -32,32 +66,273 @@ func (s *scope) AddFile(name string) error {
 		Equity: true,
 		Path:   name,
 		Target: strings.TrimSuffix(name, path.Ext(name)),
+		Output: outputLedger,
 	})
 
 	return nil
 }
 
+// Validate reads every datafile and reports its category roots, unknown ones are added with a guessed role
 func (s *scope) Validate() ([]string, error) {
 	messages := make([]string, 0)
 
-	_ = s.iterateDatafiles(func(d *datafile) error {
-		_ = *d.db.GetAccounts()
+	err := s.iterateDatafiles(func(d *datafile) error {
+		count := 0
+		analysis := ability_cash.NewClassifiersAnalysis(d.db)
 
-		messages = append(messages, fmt.Sprintf("file %s is ok; found %d transactions\n", d.Path, len(*d.db.GetTransactions())))
+		err := schema.EachTransaction(d.db, func(tx ledger.Transaction) error {
+			count++
+			analysis.Add(tx)
+			return nil
+		})
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.name(), err)
+		}
+
+		messages = append(messages, fmt.Sprintf("file %s is ok; found %d transactions", d.name(), count))
+
+		for _, problem := range schema.Problems(d.db) {
+			messages = append(messages, fmt.Sprintf("  skipped %s", problem))
+		}
+
+		for _, classifier := range analysis.Classifiers() {
+			messages = append(messages, s.classifierMessage(classifier))
+		}
+
+		if s.Commodities == nil {
+			s.Commodities = make(commodities)
+		}
+
+		for _, code := range s.Commodities.add(*d.db.GetCurrencies()) {
+			messages = append(messages, fmt.Sprintf("  currency %s: added as commodity %s", code, code))
+		}
 
 		return nil
 	})
 
-	return messages, nil
+	return messages, err
+}
+
+func (s *scope) classifierMessage(classifier *ability_cash.Classifier) string {
+	role, known := s.Categories[classifier.Name]
+
+	if !known {
+		role = classifier.GuessRole()
+		s.Categories[classifier.Name] = role
+	}
+
+	message := fmt.Sprintf("  %s: %d uses, %d values", classifier.Name, classifier.Uses, classifier.Values)
+
+	if len(classifier.Samples) > 0 {
+		message += fmt.Sprintf(" (%s)", strings.Join(classifier.Samples, ", "))
+	}
+
+	if known {
+		return fmt.Sprintf("%s; configured as %s", message, role)
+	}
+
+	return fmt.Sprintf("%s; added as %s, guessed", message, role)
 }
 
-func (s *scope) Export() error {
-	return s.iterateDatafiles(func(d *datafile) error {
-		return d.export(s.Categories)
+func (s *scope) Export(options ExportOptions) ([]string, error) {
+	messages := make([]string, 0)
+
+	if err := ability_cash.CompileRules(s.Rules); err != nil {
+		return messages, err
+	}
+
+	if err := s.Journal.validate(options); err != nil {
+		return messages, err
+	}
+
+	if err := s.Commodities.validate(); err != nil {
+		return messages, err
+	}
+
+	if err := ability_cash.CheckCostRules(s.Costs); err != nil {
+		return messages, err
+	}
+
+	merged := make([]*memoryFiles, 0)
+
+	err := s.iterateDatafiles(func(d *datafile) error {
+		d.memory = nil
+		d.templates = s.templatesDir()
+		d.commodities = s.Commodities
+
+		merge := s.Journal != nil && d.Output != outputBeancount
+
+		if options.DryRun || merge {
+			d.memory = newMemoryFiles()
+			defer func() { d.memory = nil }()
+		}
+
+		if err := d.export(s, options); err != nil {
+			return fmt.Errorf("%s: %w", d.name(), err)
+		}
+
+		d.reportProblems()
+
+		if options.DryRun {
+			d.messages = append(d.messages, d.memory.summary.String())
+		}
+
+		for _, m := range d.messages {
+			messages = append(messages, fmt.Sprintf("%s: %s", d.name(), m))
+		}
+
+		if merge {
+			merged = append(merged, d.memory)
+			return nil
+		}
+
+		if d.memory == nil {
+			return nil
+		}
+
+		if err := writeOutput(d.memory, options, &messages); err != nil {
+			return fmt.Errorf("%s: %w", d.name(), err)
+		}
+
+		return nil
 	})
+
+	if err != nil || s.Journal == nil {
+		return messages, err
+	}
+
+	output := newMemoryFiles()
+	s.Journal.merge(merged, output)
+
+	return messages, writeOutput(output, options, &messages)
+}
+
+// writeOutput writes the files kept in memory, or shows how they differ from the current ones in a dry run
+func writeOutput(output *memoryFiles, options ExportOptions, messages *[]string) error {
+	if !options.DryRun {
+		return output.flush()
+	}
+
+	diffs, err := output.diffs()
+
+	*messages = append(*messages, diffs...)
+
+	return err
+}
+
+// Reconcile lists the first transaction where each account stops matching the running balance of AbilityCash,
+// and the transactions skipped by the reader
+func (s *scope) Reconcile() ([]string, error) {
+	messages := make([]string, 0)
+
+	if err := ability_cash.CompileRules(s.Rules); err != nil {
+		return messages, err
+	}
+
+	err := s.iterateDatafiles(func(d *datafile) error {
+		converter := d.converter(s)
+
+		// without the opening transaction the initial balances are counted apart
+		var opening []ledger.TxItem
+		if !converter.GenerateEquity {
+			opening = converter.OpeningBalance().Items
+		}
+
+		divergences, err := ability_cash.Reconcile(converter.Transactions(), opening)
+
+		if converterErr := converter.Err(); converterErr != nil {
+			err = converterErr
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.name(), err)
+		}
+
+		// a skipped transaction breaks the running balances after it
+		for _, problem := range schema.Problems(d.db) {
+			messages = append(messages, fmt.Sprintf("%s: skipped %s", d.name(), problem))
+		}
+
+		for _, divergence := range divergences {
+			messages = append(messages, fmt.Sprintf(
+				"%s: %s diverges on %s: AbilityCash %s %s, computed %s %s",
+				d.name(), divergence.Account, describe(divergence.Tx),
+				divergence.Expected, divergence.Currency, divergence.Actual, divergence.Currency,
+			))
+		}
+
+		return nil
+	})
+
+	return messages, err
+}
+
+// Verify parses the written journals back and checks that transactions balance and accounts are declared
+func (s *scope) Verify() ([]string, error) {
+	messages := make([]string, 0)
+
+	if s.Journal != nil {
+		journal := ledger.NewJournal()
+
+		if err := journal.ReadFile(s.Journal.path()); err != nil {
+			return messages, err
+		}
+
+		return verifyJournal(journal, messages), nil
+	}
+
+	for _, d := range s.outputs() {
+		// beancount files are checked with bean-check
+		if !d.Active || d.Output == outputBeancount {
+			continue
+		}
+
+		journal, err := d.readJournal()
+		if err != nil {
+			return messages, fmt.Errorf("%s: %w", d.name(), err)
+		}
+
+		messages = verifyJournal(journal, messages)
+	}
+
+	return messages, nil
+}
+
+func verifyJournal(journal *ledger.Journal, messages []string) []string {
+	journal.Verify()
+
+	for _, e := range journal.Errors {
+		messages = append(messages, e.Error())
+	}
+
+	return messages
+}
+
+// templatesDir overrides the embedded templates, missing files fall back to them
+func (s *scope) templatesDir() fs.FS {
+	if s.Templates == "" {
+		return nil
+	}
+
+	return os.DirFS(s.Templates)
+}
+
+// outputs are the datafiles which get their own journals
+func (s *scope) outputs() []*datafile {
+	if s.Merge != nil {
+		s.Merge.Active = true
+		return []*datafile{s.Merge}
+	}
+
+	return s.Datafiles
 }
 
 func (s *scope) iterateDatafiles(callback func(*datafile) error) error {
+	if s.Merge != nil {
+		return s.iterateMerged(callback)
+	}
+
 	var err error
 
 	for _, datafile := range s.Datafiles {
@@ -65,9 +340,10 @@ func (s *scope) iterateDatafiles(callback func(*datafile) error) error {
 			continue
 		}
 		if datafile.db, err = datafile.readDb(); err != nil {
-			return err
+			return fmt.Errorf("%s: %w", datafile.Path, err)
 		}
 
+		datafile.messages = nil
 		err = callback(datafile)
 
 		if err != nil {
@@ -77,3 +353,36 @@ func (s *scope) iterateDatafiles(callback func(*datafile) error) error {
 
 	return nil
 }
+
+// iterateMerged joins all active datafiles into the merge one
+func (s *scope) iterateMerged(callback func(*datafile) error) error {
+	dbs := make([]schema.Database, 0, len(s.Datafiles))
+
+	for _, d := range s.Datafiles {
+		if !d.Active {
+			continue
+		}
+
+		db, err := d.readDb()
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.Path, err)
+		}
+
+		dbs = append(dbs, db)
+	}
+
+	merged, err := ability_cash.MergeDatabases(dbs)
+	if err != nil {
+		return err
+	}
+
+	d := s.Merge
+	d.Active, d.db = true, merged
+	d.messages = []string{fmt.Sprintf("%d datafiles merged, %d duplicates dropped", len(dbs), merged.Duplicates)}
+
+	for _, conflict := range merged.Conflicts {
+		d.messages = append(d.messages, fmt.Sprintf("conflict: %s differs between datafiles, the later one is kept", describe(conflict.Kept)))
+	}
+
+	return callback(d)
+}
@@ -0,0 +1,129 @@
+package scope
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Bishop/abilitycash2ledger/ability_cash/schema"
+	"github.com/Bishop/abilitycash2ledger/ledger"
+	"github.com/Bishop/abilitycash2ledger/templates"
+)
+
+var plainCommodity = regexp.MustCompile(`^[\pL\pS]+$`)
+
+// getTemplate prefers the dialect specific template and falls back to the common one,
+// a template of the override directory wins over the embedded one of the same name
+func getTemplate(override fs.FS, funcs template.FuncMap, dialect string, name string) (*template.Template, error) {
+	fileName := fmt.Sprintf("%s.go.tmpl", name)
+
+	candidates := []string{fileName}
+	if dialect != "" {
+		candidates = []string{path.Join(dialect, fileName), fileName}
+	}
+
+	sources := []fs.FS{templates.Default}
+	if override != nil {
+		sources = []fs.FS{override, templates.Default}
+	}
+
+	for _, candidate := range candidates {
+		for _, source := range sources {
+			if _, err := fs.Stat(source, candidate); err != nil {
+				continue
+			}
+
+			return template.New(fileName).Funcs(templateFuncs).Funcs(funcs).ParseFS(source, candidate)
+		}
+	}
+
+	return nil, fmt.Errorf("template %s not found", fileName)
+}
+
+var templateFuncs = template.FuncMap{
+	"acc":          acc,
+	"signed":       signed,
+	"quote":        quote,
+	"sample":       sample,
+	"price":        price,
+	"date":         date,
+	"formatDate":   formatDate,
+	"amount":       amount,
+	"signedAmount": signedAmount,
+	"commodity":    commodity,
+	"metaKey":      metaKey,
+	"metaValue":    metaValue,
+}
+
+func acc(account string) string {
+	return fmt.Sprintf("%-40s", account)
+}
+
+func signed(amount ledger.Amount) string {
+	// keep the column aligned: reserve a place for the minus sign
+	if amount.Sign() < 0 {
+		return amount.String()
+	}
+
+	return " " + amount.String()
+}
+
+func sample(precision uint) (ledger.Amount, error) {
+	return ledger.NewAmount(1000, 0).Rescale(precision)
+}
+
+// price of one unit of Currency1 in Currency2
+func price(rate schema.Rate) (ledger.Amount, error) {
+	amount, err := rate.Amount2.Quo(rate.Amount1, pricePrecision)
+
+	return amount.Normalize(), err
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func date(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// formatDate takes the layout first to be used in pipelines: {{.Date | formatDate "2006/01/02"}}
+func formatDate(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+func amount(a ledger.Amount, currency string) string {
+	return fmt.Sprintf("%s %s", a, commodity(currency))
+}
+
+func signedAmount(a ledger.Amount, currency string) string {
+	return fmt.Sprintf("%s %s", signed(a), commodity(currency))
+}
+
+// commodity quotes symbols with digits, spaces or punctuation, ledger reads them as a part of the amount otherwise
+func commodity(currency string) string {
+	if currency == "" || plainCommodity.MatchString(currency) {
+		return currency
+	}
+
+	return quote(currency)
+}
+
+// metaKey makes a tag name: no spaces or colons
+func metaKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ':' || r == ' ' || r == '\t' {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(key))
+}
+
+// metaValue keeps a comment or a tag value on one line
+func metaValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
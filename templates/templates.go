@@ -0,0 +1,7 @@
+// Package templates holds the default output templates, they are embedded into the binary
+package templates
+
+import "embed"
+
+//go:embed *.go.tmpl hledger/*.go.tmpl
+var Default embed.FS